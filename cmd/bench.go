@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"radsched/common"
+	"radsched/utils"
+	"github.com/spf13/cobra"
+)
+
+var BenchCmd = &cobra.Command{
+	Use:   "bench [function names]",
+	Short: "Benchmark the scheduling policies against running in the datacenter",
+	Long:  "This command invokes each function (all registered functions by default) a number of times under each selected policy and the datacenter baseline, and reports the observed latency and the speedup over the baseline. With --mock invocations go to the mock provider instead of the providers in the region catalog. Exploration state is kept in memory and the data files are not modified.",
+	Run: func(cmd *cobra.Command, args []string) {
+		policyNames, _ := cmd.Flags().GetStringSlice("policies")
+		trials, _ := cmd.Flags().GetInt("trials")
+		schedule, _ := cmd.Flags().GetString("schedule")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		perTrial, _ := cmd.Flags().GetBool("per-trial")
+		mock, _ := cmd.Flags().GetBool("mock")
+		if schedule != "" && !utils.IsSchedule(strings.ToUpper(schedule)) {
+			log.Fatalf("Unknown exploration schedule %s (expected one of %s)", schedule, strings.Join(utils.Schedules, ", "))
+		}
+		if format != "table" && format != "csv" && format != "json" {
+			log.Fatalf("Unknown format %s (expected table, csv or json)", format)
+		}
+		if trials < 1 {
+			log.Fatalf("--trials must be positive")
+		}
+
+		functions, err := benchFunctions(args)
+		if err != nil {
+			log.Fatalf("Failed to fetch function info: %v", err)
+		}
+		var invoker utils.Invoker = utils.ProviderInvoker{}
+		if mock {
+			invoker = utils.MockProvider{}
+		}
+		report, err := utils.RunBench(functions, policyNames, trials, invoker, strings.ToUpper(schedule), seed)
+		if err != nil {
+			log.Fatalf("Benchmark failed: %v", err)
+		}
+
+		var out io.Writer = os.Stdout
+		if output != "" {
+			file, err := os.Create(output)
+			if err != nil {
+				log.Fatalf("Failed to create %s: %v", output, err)
+			}
+			defer file.Close()
+			out = file
+		}
+		switch {
+		case format == "json":
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(report)
+		case format == "csv" && perTrial:
+			err = utils.WriteBenchTrialsCSV(out, report.Trials)
+		case format == "csv":
+			err = utils.WriteBenchSummaryCSV(out, report.Summaries)
+		default:
+			printBenchSummaries(out, report.Summaries)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	},
+}
+
+// Functions named on the command line, or every registered function sorted by name
+func benchFunctions(names []string) ([]common.FunctionInfo, error) {
+	functionMap, err := utils.GetFunctionsAsMap()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		for name := range functionMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var functions []common.FunctionInfo
+	for _, name := range names {
+		function, exists := functionMap[strings.ToLower(name)]
+		if !exists {
+			return nil, fmt.Errorf("function %s is unknown", name)
+		}
+		functions = append(functions, function)
+	}
+	return functions, nil
+}
+
+func printBenchSummaries(out io.Writer, summaries []utils.BenchSummary) {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "FUNCTION\tPOLICY\tTRIALS\tFAILURES\tMEAN\tP50\tP95\tMIN\tMAX\tSTDDEV\tSPEEDUP")
+	for _, summary := range summaries {
+		speedup := "-"
+		if summary.Speedup > 0 {
+			speedup = fmt.Sprintf("%.2fx", summary.Speedup)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%s\n",
+			summary.Function, summary.Policy, summary.Trials, summary.Failures, summary.Mean, summary.P50,
+			summary.P95, summary.Min, summary.Max, summary.StdDev, speedup)
+	}
+	writer.Flush()
+}
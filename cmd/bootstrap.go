@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"log"
+	"time"
 	"github.com/spf13/cobra"
 	"radsched/utils"
 )
@@ -16,44 +17,135 @@ var BootstrapCmd = &cobra.Command{
 
 // Collects function, latency, and consistency information
 func bootstrap(cmd *cobra.Command, args []string) {
+	consistencyOnly, _ := cmd.Flags().GetBool("consistency-only")
+	if consistencyOnly {
+		bootstrapConsistency()
+		return
+	}
+
 	// get registered functions
+	started := time.Now()
 	functions, err := utils.LoadFunctions()
 	if err != nil {
+		recordRefresh("functions", started, err)
 		log.Fatalf("Error fetching function data: %v\n", err)
 		return
 	}
 	err = utils.StoreFunctions(functions)
+	recordRefresh("functions", started, err)
 	if err != nil {
 		log.Fatalf("Failed to save function data to JSON: %v", err)
 	}
 	log.Println("Successfully saved the latest function data to function_registry.json")
 
 	// get client to edge times
+	started = time.Now()
 	locations, err := utils.GetClientToEdgeRTT()
 	if err != nil {
+		recordRefresh("client_rtts", started, err)
 		log.Fatalf("Failed to retreive client to edge data: %v", err)
 	}
 	err = utils.StoreLocations(locations)
+	recordRefresh("client_rtts", started, err)
 	if err != nil {
 		log.Fatalf("Failed to save client to edge data to JSON: %v", err)
 	}
 	log.Println("Successfully saved the client to edge RTT data")
+	if clientID, _ := cmd.Flags().GetString("client-id"); clientID != "" {
+		saveClientProfile(cmd, clientID)
+	}
+
+	// get client to edge throughput, if a probe object is configured
+	if utils.GetConfig().Bandwidth.ProbeURL != "" {
+		started = time.Now()
+		bandwidth, err := utils.GetClientToEdgeBandwidth()
+		if err != nil {
+			recordRefresh("bandwidth", started, err)
+			log.Fatalf("Failed to measure client to edge bandwidth: %v", err)
+		}
+		err = utils.StoreBandwidth(bandwidth)
+		recordRefresh("bandwidth", started, err)
+		if err != nil {
+			log.Fatalf("Failed to save client to edge bandwidth data: %v", err)
+		}
+		log.Println("Successfully saved the client to edge bandwidth data")
+	}
 
 	// get edge to datacenter times
+	started = time.Now()
 	data, err := utils.GetEdgeToDataCenterRTT()
 	if err != nil {
-		log.Fatalf("Error invoking Lambda: %v", err)
+		recordRefresh("edge_rtts", started, err)
+		log.Fatalf("Failed to measure edge to datacenter RTTs: %v", err)
+	}
+	err = utils.SaveRTTDataToJSON(data)
+	recordRefresh("edge_rtts", started, err)
+	if err != nil {
+		log.Fatalf("Failed to save edge to datacenter RTT data: %v", err)
 	}
-	utils.SaveRTTDataToJSON(data)
 	log.Println("Successfully saved the edge to datacenter RTT data")
+	warnMeasurementGaps()
+
+	bootstrapConsistency()
+}
 
-	// get and store consistency data 
-	if err := utils.UpdateConsistencyByFunction(); err != nil {
+// Fetches and stores function and edge-function consistency data
+func bootstrapConsistency() {
+	started := time.Now()
+	err := utils.UpdateConsistencyByFunction()
+	recordRefresh("function_consistency", started, err)
+	if err != nil {
 		log.Fatalf("Failed to update global consistency data: %v", err)
 	}
 	log.Println("Updated function-level consistency stats")
-	if err := utils.UpdateConsistencyByEdgeFunction(); err != nil {
+	started = time.Now()
+	err = utils.UpdateConsistencyByEdgeFunction()
+	recordRefresh("edge_consistency", started, err)
+	if err != nil {
 		log.Fatalf("Failed to update edge-function consistency data: %v", err)
 	}
 	log.Println("Updated edge-function consistency stats")
 }
+
+// Records a bootstrap step's duration and outcome for serve's /metrics
+func recordRefresh(step string, started time.Time, err error) {
+	if recordErr := utils.RecordRefresh(step, started, err); recordErr != nil {
+		log.Printf("Failed to record %s refresh status: %v", step, recordErr)
+	}
+}
+
+// Saves the RTTs just measured as the profile of the client running bootstrap
+func saveClientProfile(cmd *cobra.Command, clientID string) {
+	region, _ := cmd.Flags().GetString("client-region")
+	prefixes, _ := cmd.Flags().GetStringSlice("client-prefixes")
+	rtts, err := utils.GetLocations()
+	if err != nil {
+		log.Fatalf("Failed to load client to edge data: %v", err)
+	}
+	profile := utils.ClientProfile{
+		ID:         clientID,
+		Region:     region,
+		Prefixes:   prefixes,
+		RTTs:       rtts,
+		MeasuredAt: time.Now(),
+	}
+	if err := utils.StoreClientProfile(profile); err != nil {
+		log.Fatalf("Failed to save client profile: %v", err)
+	}
+	log.Printf("Saved the client RTTs as client profile %s", clientID)
+}
+
+// Lists the links that are unreachable or missing from the RTT data
+func warnMeasurementGaps() {
+	locations, err := utils.GetLocations()
+	if err != nil {
+		log.Fatalf("Failed to load client to edge data: %v", err)
+	}
+	edges, err := utils.GetEdges()
+	if err != nil {
+		log.Fatalf("Failed to load edge to datacenter data: %v", err)
+	}
+	for _, gap := range utils.MeasurementGaps(locations, edges) {
+		log.Printf("Warning: %s", gap)
+	}
+}
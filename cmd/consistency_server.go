@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"log"
+	"net/http"
+	"github.com/spf13/cobra"
+	"radsched/utils"
+)
+
+var ConsistencyServerCmd = &cobra.Command{
+	Use:   "consistency-server",
+	Short: "Serve consistency stats from local files",
+	Long:  "This command serves the hit_ratio and hit_ratio_v2 endpoints of the consistency storage server from local JSON files, so bootstrap can run offline.",
+	Args:  cobra.NoArgs,
+	Run:   runConsistencyServer,
+}
+
+// Serves function and edge-function hit ratios until interrupted
+func runConsistencyServer(cmd *cobra.Command, args []string) {
+	addr, _ := cmd.Flags().GetString("addr")
+	functionFile, _ := cmd.Flags().GetString("function-stats")
+	edgeFile, _ := cmd.Flags().GetString("edge-stats")
+	token, _ := cmd.Flags().GetString("auth-token")
+
+	cfg := utils.GetConfig()
+	if functionFile == "" {
+		functionFile = utils.DataPath(utils.FunctionConsistencyFile)
+	}
+	if edgeFile == "" {
+		edgeFile = utils.DataPath(utils.EdgeConsistencyFile)
+	}
+	if token == "" {
+		token = cfg.Consistency.AuthToken
+	}
+	server := &utils.ConsistencyStatsServer{
+		FunctionStatsFile: functionFile,
+		EdgeStatsFile:     edgeFile,
+		AuthHeader:        cfg.Consistency.AuthHeader,
+		AuthToken:         token,
+	}
+
+	log.Printf("Serving consistency stats on %s (functions: %s, edges: %s)", addr, functionFile, edgeFile)
+	if err := http.ListenAndServe(addr, server.Handler()); err != nil {
+		log.Fatalf("Consistency server failed: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"radsched/utils"
+	"github.com/spf13/cobra"
+)
+
+var EvaluateCmd = &cobra.Command{
+	Use:   "evaluate [decision log]",
+	Short: "Estimate how other policies would have done on logged decisions",
+	Long:  "This command reads a JSON lines decision log, such as one written by simulate --decision-log or the audit log in the data directory when none is given, and uses inverse propensity scoring over the logged choice probabilities to estimate the mean latency and inconsistency rate each target policy would have had, without running it. Targets are logged, greedy, latency, epsilon:<rate> and thompson.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targets, _ := cmd.Flags().GetStringSlice("targets")
+		policy, _ := cmd.Flags().GetString("policy")
+		seed, _ := cmd.Flags().GetInt64("seed")
+
+		var records []utils.DecisionRecord
+		var err error
+		if len(args) == 1 {
+			records, err = utils.LoadDecisionLog(args[0])
+		} else {
+			records, err = utils.LoadDecisionHistory()
+		}
+		if err != nil {
+			log.Fatalf("Failed to load decision log: %v", err)
+		}
+		var logged []utils.DecisionRecord
+		for _, record := range records {
+			if policy == "" || strings.EqualFold(record.Policy, policy) {
+				logged = append(logged, record)
+			}
+		}
+		results, err := utils.Evaluate(logged, targets, seed)
+		if err != nil {
+			log.Fatalf("Evaluation failed: %v", err)
+		}
+		printEvaluationResults(results)
+	},
+}
+
+func printEvaluationResults(results []utils.EvaluationResult) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TARGET\tDECISIONS\tMATCHED\tEFFECTIVE N\tIPS LATENCY\tSNIPS LATENCY\tIPS INCONSISTENT\tSNIPS INCONSISTENT")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f%%\t%.2f%%\n",
+			result.Target, result.Decisions, result.Matched, result.EffectiveSamples, result.IPSLatency,
+			result.SNIPSLatency, result.IPSInconsistency*100, result.SNIPSInconsistency*100)
+	}
+	writer.Flush()
+}
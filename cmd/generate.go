@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"radsched/utils"
+	"github.com/spf13/cobra"
+)
+
+var GenerateCmd = &cobra.Command{
+	Use:   "generate [output dir]",
+	Short: "Generate a synthetic topology and request trace",
+	Long:  "This command writes a synthetic region catalog, client profiles, RTT matrices, function registry and consistency statistics to the output directory in the formats bootstrap produces, together with a trace.jsonl for simulate and a radsched_config.json pointing at the directory. RTTs carry jitter, RTTs and the request rate follow the time of day, and consistency drifts over the window. Hourly RTT and consistency snapshots go to network_snapshots.json for simulate, and the other data files describe the start of the window. The same seed always gives the same output.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := utils.GeneratorOptions{}
+		opts.Regions, _ = cmd.Flags().GetInt("regions")
+		opts.Clients, _ = cmd.Flags().GetInt("clients")
+		opts.Functions, _ = cmd.Flags().GetInt("functions")
+		opts.Hours, _ = cmd.Flags().GetFloat64("hours")
+		opts.RequestsPerHour, _ = cmd.Flags().GetFloat64("requests-per-hour")
+		opts.Jitter, _ = cmd.Flags().GetFloat64("jitter")
+		opts.Diurnal, _ = cmd.Flags().GetFloat64("diurnal")
+		opts.Seed, _ = cmd.Flags().GetInt64("seed")
+		start, _ := cmd.Flags().GetString("start")
+		var err error
+		if opts.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			log.Fatalf("Invalid start time %s: %v", start, err)
+		}
+
+		topology, err := utils.NewSyntheticTopology(opts)
+		if err != nil {
+			log.Fatalf("Failed to generate topology: %v", err)
+		}
+		if err := topology.Write(args[0]); err != nil {
+			log.Fatalf("Failed to write generated data: %v", err)
+		}
+		fmt.Printf("Generated %d regions, %d clients and %d functions in %s\n", opts.Regions, opts.Clients, opts.Functions, args[0])
+	},
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+	"radsched/utils"
+	"github.com/spf13/cobra"
+)
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show logged scheduling decisions",
+	Long:  "This command reads the decision audit log in the data directory, including rotated files, and prints the decisions that match the filters, most recent last.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := utils.DecisionFilter{}
+		filter.Function, _ = cmd.Flags().GetString("function")
+		filter.Client, _ = cmd.Flags().GetString("client")
+		filter.Policy, _ = cmd.Flags().GetString("policy")
+		filter.Location, _ = cmd.Flags().GetString("location")
+		filter.Explored, _ = cmd.Flags().GetBool("explored")
+		filter.Limit, _ = cmd.Flags().GetInt("limit")
+		since, _ := cmd.Flags().GetDuration("since")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if since > 0 {
+			filter.Since = time.Now().Add(-since)
+		}
+
+		records, err := utils.LoadDecisionHistory()
+		if err != nil {
+			log.Fatalf("Failed to load decision log: %v", err)
+		}
+		records = utils.FilterDecisions(records, filter)
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			for _, record := range records {
+				if err := encoder.Encode(record); err != nil {
+					log.Fatalf("Failed to write decision: %v", err)
+				}
+			}
+			return
+		}
+		printDecisions(records)
+	},
+}
+
+func printDecisions(records []utils.DecisionRecord) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tFUNCTION\tCLIENT\tPOLICY\tLOCATION\tESTIMATE\tOBSERVED\tEPSILON\tEXPLORED\tCANDIDATES")
+	for _, record := range records {
+		client, observed, epsilon := record.Client, "-", "-"
+		if client == "" {
+			client = "-"
+		}
+		if record.Outcome != nil {
+			observed = fmt.Sprintf("%.2f", record.Outcome.Latency)
+		}
+		if record.Policy == "weighted" {
+			epsilon = fmt.Sprintf("%.3f", record.Epsilon)
+		}
+		eligible := 0
+		for _, candidate := range record.Candidates {
+			if candidate.Eligible {
+				eligible++
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%.2f\t%s\t%s\t%t\t%d/%d\n",
+			record.Timestamp.Local().Format(time.DateTime), record.Function, client, record.Policy, record.Location,
+			record.Estimate, observed, epsilon, record.Explored, eligible, len(record.Candidates))
+	}
+	writer.Flush()
+}
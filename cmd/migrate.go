@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"github.com/spf13/cobra"
+	"radsched/utils"
+)
+
+var MigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade local data files to the current format",
+	Long:  "This command upgrades the function registry, RTT files, consistency caches and epsilon state in the data directory to the current versioned format, keeping a backup of every file it rewrites.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		results, err := utils.MigrateDataFiles(dryRun)
+		for _, result := range results {
+			for _, invalid := range result.Invalid {
+				fmt.Printf("%s: %s, fix it with prepare\n", result.File, invalid)
+			}
+			if result.Skipped != "" {
+				fmt.Printf("%s: skipped (%s)\n", result.File, result.Skipped)
+				continue
+			}
+			if dryRun {
+				fmt.Printf("%s: v%d -> v%d (would back up to %s)\n", result.File, result.FromVersion, utils.DataFileVersion, result.Backup)
+				continue
+			}
+			fmt.Printf("%s: v%d -> v%d (backup: %s)\n", result.File, result.FromVersion, utils.DataFileVersion, result.Backup)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if dryRun {
+			fmt.Println("Dry run, no files were changed.")
+		}
+	},
+}
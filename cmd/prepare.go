@@ -8,29 +8,50 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 	"radsched/common"
 	"radsched/utils" 
 	"github.com/spf13/cobra"
 )
 
 type PreparedFunction struct {
-	FunctionName  string `json:"function_name"`
-	ExecutionTime string `json:"execution_time"`
-	FunctionURL   string `json:"function_url"`
-	Datacenter    string `json:"datacenter"`
-	Date          string `json:"date"`
+	SchemaVersion int                       `json:"schema_version"`
+	FunctionName  string                    `json:"function_name"`
+	ExecutionTime common.Duration           `json:"execution_time"`
+	MemoryMB      int                       `json:"memory_mb,omitempty"`
+	Runtime       string                    `json:"runtime,omitempty"`
+	FunctionURL   string                    `json:"function_url"`
+	Datacenter    string                    `json:"datacenter"`
+	Payload       common.PayloadEstimate    `json:"payload"`
+	StateKeys     []string                  `json:"state_keys,omitempty"`
+	StateAccess   common.StateAccessProfile `json:"state_access"`
+	Owner         string                    `json:"owner,omitempty"`
+	Tags          []string                  `json:"tags,omitempty"`
+	Date          string                    `json:"date"`
 }
 
 var PrepareCmd = &cobra.Command{
-	Use:   "prepare [function name] [function execution time (ms)] [function datacenter]",
+	Use:   "prepare [function name] [function execution time, e.g. 100ms] [function datacenter]",
 	Short: "Prepare a specific function to be executed on the Rad-Sched scheduler",
 	Long:  "This command prepares a specific function by adding it to both the local and Radical function repositories.",
 	Args:  cobra.ExactArgs(3),
 	Run: func(cmd *cobra.Command, args []string) {
-		function := common.FunctionInfo{
-			FunctionName:  strings.ToLower(args[0]),
-			ExecutionTime: args[1],
-			Datacenter:    strings.ToLower(args[2]),
+		executionTime, err := common.ParseDuration(args[1])
+		if err != nil {
+			log.Fatalf("Invalid execution time: %v", err)
+		}
+		function := functionFromFlags(cmd)
+		function.FunctionName = strings.ToLower(args[0])
+		function.ExecutionTime = executionTime
+		function.Datacenter = strings.ToLower(args[2])
+		if err := function.Validate(); err != nil {
+			log.Fatalf("Invalid function: %v", err)
+		}
+		if region, known := utils.GetRegionCatalog().Get(function.Datacenter); !known || !region.Primary {
+			log.Fatalf("Datacenter %s is not a primary region in the region catalog", function.Datacenter)
+		}
+		if reason := utils.ConstraintViolation(function.Constraints, function.Datacenter); reason != "" {
+			log.Fatalf("Datacenter %s violates the function's placement constraints: %s", function.Datacenter, reason)
 		}
 		if err := saveToLocalFunctionRegistry(function); err != nil {
 			log.Fatalf("Failed to save to local function registry: %v", err)
@@ -42,14 +63,71 @@ var PrepareCmd = &cobra.Command{
 	},
 }
 
+// Builds the optional parts of the function description from the prepare flags
+func functionFromFlags(cmd *cobra.Command) common.FunctionInfo {
+	memory, _ := cmd.Flags().GetInt("memory")
+	runtime, _ := cmd.Flags().GetString("runtime")
+	functionURL, _ := cmd.Flags().GetString("url")
+	requestBytes, _ := cmd.Flags().GetInt64("request-bytes")
+	responseBytes, _ := cmd.Flags().GetInt64("response-bytes")
+	stateKeys, _ := cmd.Flags().GetStringSlice("state-keys")
+	stateAccesses, _ := cmd.Flags().GetInt("state-accesses")
+	writeFraction, _ := cmd.Flags().GetFloat64("write-fraction")
+	owner, _ := cmd.Flags().GetString("owner")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	return common.FunctionInfo{
+		SchemaVersion: common.FunctionSchemaVersion,
+		MemoryMB:      memory,
+		Runtime:       strings.ToLower(runtime),
+		FunctionURL:   functionURL,
+		Payload: common.PayloadEstimate{
+			RequestBytes:  requestBytes,
+			ResponseBytes: responseBytes,
+		},
+		StateKeys:   stateKeys,
+		StateAccess: common.StateAccessProfile{
+			SequentialAccesses: stateAccesses,
+			WriteFraction:      writeFraction,
+		},
+		Owner:       owner,
+		Tags:        tags,
+		Constraints: constraintsFromFlags(cmd),
+	}
+}
+
+// Builds placement constraints from the prepare flags, or nil if none are set
+func constraintsFromFlags(cmd *cobra.Command) *common.PlacementConstraints {
+	regions, _ := cmd.Flags().GetStringSlice("allowed-regions")
+	jurisdictions, _ := cmd.Flags().GetStringSlice("allowed-jurisdictions")
+	excluded, _ := cmd.Flags().GetStringSlice("exclude-edges")
+	tags, _ := cmd.Flags().GetStringSlice("required-tags")
+	if len(regions) == 0 && len(jurisdictions) == 0 && len(excluded) == 0 && len(tags) == 0 {
+		return nil
+	}
+	return &common.PlacementConstraints{
+		AllowedRegions:       lowerAll(regions),
+		AllowedJurisdictions: lowerAll(jurisdictions),
+		ExcludedEdges:        lowerAll(excluded),
+		RequiredTags:         tags,
+	}
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, value := range values {
+		lowered = append(lowered, strings.ToLower(strings.TrimSpace(value)))
+	}
+	return lowered
+}
+
 // Registers or updates function in local registry 
 func saveToLocalFunctionRegistry(function common.FunctionInfo) error {
 	functionsMap, err := utils.GetFunctionsAsMap()
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to load existing functions: %v", err)
 	}
 	functionsList, err := utils.GetFunctionsAsList()
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to load existing functions: %v", err)
 	}
 	_, exists := functionsMap[function.FunctionName] 
@@ -64,32 +142,27 @@ func saveToLocalFunctionRegistry(function common.FunctionInfo) error {
 	} else {
 		functionsList = append(functionsList, function)
 		fmt.Printf("Function '%s' added to the local registry.\n", function.FunctionName)
-		log.Println(functionsList)
-	}
-
-	registry, err := os.Create("function_registry")
-	if err != nil {
-		return err
 	}
-	defer registry.Close()
 
-	encoder := json.NewEncoder(registry)
-	encoder.SetIndent("", "  ") 
-	if err := encoder.Encode(functionsList); err != nil {
-		return err
-	}
-
-	return nil
+	return utils.StoreFunctions(functionsList)
 }
 
 // Registers or updates function in Radical registry 
 func registerFunctionWithRadical(function common.FunctionInfo) error {
 	preparedFunction := PreparedFunction{
+		SchemaVersion: common.FunctionSchemaVersion,
 		FunctionName: function.FunctionName,
 		ExecutionTime: function.ExecutionTime,
-		FunctionURL: "http",
+		MemoryMB: function.MemoryMB,
+		Runtime: function.Runtime,
+		FunctionURL: function.FunctionURL,
 		Datacenter: function.Datacenter,
-		Date: "0000-00-00",
+		Payload: function.Payload,
+		StateKeys: function.StateKeys,
+		StateAccess: function.StateAccess,
+		Owner: function.Owner,
+		Tags: function.Tags,
+		Date: time.Now().UTC().Format("2006-01-02"),
 	}
 	functionData, err := json.Marshal(preparedFunction)
 	if err != nil {
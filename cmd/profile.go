@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"github.com/spf13/cobra"
+	"radsched/utils"
+)
+
+var ProfileCmd = &cobra.Command{
+	Use:   "profile [function name]",
+	Short: "Show learned execution times of a function",
+	Long:  "This command shows the execution time distribution RadSched has learned for a function at each location, and the time it plans with.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		functionName := strings.ToLower(args[0])
+		functions, err := utils.GetFunctionsAsMap()
+		if err != nil {
+			log.Fatalf("Failed to fetch function info: %v", err)
+		}
+		function, exists := functions[functionName]
+		if !exists {
+			log.Fatalf("Function %s is unkown. Please prepare before running", functionName)
+		}
+		profiles, err := utils.LoadExecutionProfiles()
+		if err != nil {
+			log.Fatalf("Failed to load execution profiles: %v", err)
+		}
+		functionProfiles := profiles[functionName]
+
+		locations := make([]string, 0, len(functionProfiles))
+		for location := range functionProfiles {
+			locations = append(locations, location)
+		}
+		sort.Strings(locations)
+
+		fmt.Printf("Declared Execution Time: %s\n", function.ExecutionTime)
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "LOCATION\tSAMPLES\tCOLD STARTS\tMEAN\tP50\tP90\tP99\tPLANNED\tLAST INVOKED")
+		for _, location := range locations {
+			profile := functionProfiles[location]
+			fmt.Fprintf(writer, "%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%s\n",
+				location, profile.Count, profile.ColdStarts,
+				utils.Mean(profile.Samples),
+				utils.Quantile(profile.Samples, 0.5),
+				utils.Quantile(profile.Samples, 0.9),
+				utils.Quantile(profile.Samples, 0.99),
+				functionProfiles.ExecutionTime(function, location),
+				profile.LastInvoked.Format("2006-01-02 15:04:05"))
+		}
+		writer.Flush()
+	},
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"radsched/common"
+	"radsched/utils"
+	"github.com/spf13/cobra"
+)
+
+var RegionsCmd = &cobra.Command{
+	Use:   "regions",
+	Short: "List the region catalog",
+	Long:  "This command lists the regions in the region catalog (regions.json in the data directory, or the built-in catalog). Use --init to write the built-in catalog to regions.json for editing.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if initCatalog, _ := cmd.Flags().GetBool("init"); initCatalog {
+			writeDefaultRegionCatalog()
+			return
+		}
+		printRegionCatalog(utils.GetRegionCatalog())
+	},
+}
+
+// Writes the built-in catalog, refusing to overwrite an existing one
+func writeDefaultRegionCatalog() {
+	path := utils.DataPath(utils.RegionCatalogFile)
+	if _, err := os.Stat(path); err == nil {
+		log.Fatalf("%s already exists", path)
+	}
+	if err := utils.SaveRegionCatalog(common.DefaultRegionCatalog()); err != nil {
+		log.Fatalf("Failed to save region catalog: %v", err)
+	}
+	fmt.Printf("Wrote the built-in region catalog to %s\n", path)
+}
+
+func printRegionCatalog(catalog common.RegionCatalog) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "REGION\tPROVIDER\tJURISDICTION\tLOCATION\tEDGE\tPRIMARY\tPROBED\tRUNTIMES\tTAGS")
+	for _, region := range catalog {
+		location := "-"
+		if region.Location != nil {
+			location = fmt.Sprintf("%.2f,%.2f", region.Location.Latitude, region.Location.Longitude)
+		}
+		runtimes := "any"
+		if len(region.Runtimes) > 0 {
+			runtimes = strings.Join(region.Runtimes, ",")
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%t\t%t\t%t\t%s\t%s\n",
+			region.ID(), region.Provider, region.Jurisdiction, location, region.Edge, region.Primary, region.Probed, runtimes, strings.Join(region.Tags, ","))
+	}
+	writer.Flush()
+}
@@ -23,5 +23,83 @@ func InitRootCmd() {
 	RootCmd.AddCommand(BootstrapCmd)
 	RootCmd.AddCommand(PrepareCmd)
 	RootCmd.AddCommand(RunCmd)
+	RootCmd.AddCommand(ConsistencyServerCmd)
+	RootCmd.AddCommand(MigrateCmd)
+	RootCmd.AddCommand(ProfileCmd)
+	RootCmd.AddCommand(WarmCmd)
+	RootCmd.AddCommand(ServeCmd)
+	RootCmd.AddCommand(RegionsCmd)
+	RootCmd.AddCommand(SimulateCmd)
+	RootCmd.AddCommand(GenerateCmd)
+	RootCmd.AddCommand(BenchCmd)
+	RootCmd.AddCommand(EvaluateCmd)
+	RootCmd.AddCommand(HistoryCmd)
+	BootstrapCmd.Flags().Bool("consistency-only", false, "Only refresh consistency stats from the consistency server")
+	BootstrapCmd.Flags().String("client-id", "", "Also save the measured client RTTs as the client profile with this ID")
+	BootstrapCmd.Flags().String("client-region", "", "Region of the client profile")
+	BootstrapCmd.Flags().StringSlice("client-prefixes", nil, "CIDR prefixes of callers the client profile applies to")
 	RunCmd.Flags().Bool("with-weight", false, "Run the function with weight")
+	RunCmd.Flags().Bool("invoke", false, "Invoke the function at the chosen location and record its execution time")
+	RunCmd.Flags().Bool("explain", false, "Print every candidate location with its estimate or the reason it was filtered")
+	RunCmd.Flags().Int64("seed", 0, "Seed for exploration randomness (default: time-based)")
+	RunCmd.Flags().String("replay", "", "Repeat the logged decision with this ID from its seed, epsilon and candidates, without changing any state")
+	RunCmd.Flags().String("schedule", "", "Exploration schedule for --with-weight: ADAPTIVE, SMOOTH, TIME_DECAY, INVERSE_N or FIXED (default from config)")
+	PrepareCmd.Flags().Int("memory", 0, "Memory size in MB (128-10240)")
+	PrepareCmd.Flags().String("runtime", "", "Function runtime, e.g. python3.12")
+	PrepareCmd.Flags().String("url", "", "URL the function is invoked at")
+	PrepareCmd.Flags().Int64("request-bytes", 0, "Estimated request payload size in bytes")
+	PrepareCmd.Flags().Int64("response-bytes", 0, "Estimated response payload size in bytes")
+	PrepareCmd.Flags().StringSlice("state-keys", nil, "State keys the function reads or writes")
+	PrepareCmd.Flags().Int("state-accesses", 0, "Sequential reads and writes of primary state per invocation (default 1)")
+	PrepareCmd.Flags().Float64("write-fraction", 0, "Fraction of state accesses that are writes (0-1)")
+	PrepareCmd.Flags().String("owner", "", "Owner of the function")
+	PrepareCmd.Flags().StringSlice("tags", nil, "Tags describing the function")
+	PrepareCmd.Flags().StringSlice("allowed-regions", nil, "Regions the function may run in (comma separated)")
+	PrepareCmd.Flags().StringSlice("allowed-jurisdictions", nil, "Jurisdictions the function's data may reside in, e.g. us,eu")
+	PrepareCmd.Flags().StringSlice("exclude-edges", nil, "Edges the function must never run at")
+	PrepareCmd.Flags().StringSlice("required-tags", nil, "Tags (from region_tags in config) a location must carry")
+	WarmCmd.Flags().Bool("once", false, "Run a single warm-up round and exit")
+	WarmCmd.Flags().Bool("stats", false, "Print warm-up metrics and exit")
+	WarmCmd.Flags().Int("top-k", 0, "Locations kept warm per function (default from config)")
+	WarmCmd.Flags().Int("budget", 0, "Warm-up invocations allowed per hour (default from config)")
+	WarmCmd.Flags().Duration("interval", 0, "Time between rounds (default from config)")
+	SimulateCmd.Flags().StringSlice("policies", []string{"latency", "weighted", "datacenter"}, "Policies to simulate (comma separated)")
+	SimulateCmd.Flags().String("schedule", "", "Exploration schedule for the weighted policy (default from config)")
+	SimulateCmd.Flags().Int64("seed", 1, "Seed for exploration and consistency sampling")
+	SimulateCmd.Flags().String("decision-log", "", "Write every simulated decision with its propensity and outcome to this JSON lines file")
+	HistoryCmd.Flags().String("function", "", "Only decisions for this function")
+	HistoryCmd.Flags().String("client", "", "Only decisions for this client ID, IP or region")
+	HistoryCmd.Flags().String("policy", "", "Only decisions of this policy")
+	HistoryCmd.Flags().String("location", "", "Only decisions that chose this location")
+	HistoryCmd.Flags().Duration("since", 0, "Only decisions made within this long, e.g. 24h")
+	HistoryCmd.Flags().Bool("explored", false, "Only exploration steps")
+	HistoryCmd.Flags().Int("limit", 20, "Most recent decisions to show, 0 for all")
+	HistoryCmd.Flags().Bool("json", false, "Print the matching decisions as JSON lines")
+	EvaluateCmd.Flags().StringSlice("targets", []string{"logged", "greedy", "epsilon:0.05", "thompson"}, "Target policies to evaluate (comma separated)")
+	EvaluateCmd.Flags().String("policy", "weighted", "Only use decisions made by this policy; empty uses all")
+	EvaluateCmd.Flags().Int64("seed", 1, "Seed for Thompson sampling draws")
+	BenchCmd.Flags().StringSlice("policies", []string{"latency", "weighted"}, "Policies to compare with the datacenter baseline (comma separated)")
+	BenchCmd.Flags().Int("trials", 10, "Invocations per function and policy")
+	BenchCmd.Flags().String("schedule", "", "Exploration schedule for the weighted policy (default from config)")
+	BenchCmd.Flags().Int64("seed", 1, "Seed for exploration")
+	BenchCmd.Flags().String("format", "table", "Report format: table, csv or json")
+	BenchCmd.Flags().String("output", "", "Write the report to this file instead of stdout")
+	BenchCmd.Flags().Bool("per-trial", false, "With --format csv, write one row per invocation instead of the summary")
+	BenchCmd.Flags().Bool("mock", false, "Invoke through the mock provider instead of the region catalog's providers")
+	GenerateCmd.Flags().Int("regions", 6, "Number of regions, drawn from the built-in catalog")
+	GenerateCmd.Flags().Int("clients", 3, "Number of client profiles")
+	GenerateCmd.Flags().Int("functions", 10, "Number of functions; execution times cycle from 5 ms to 3125 ms")
+	GenerateCmd.Flags().Float64("hours", 24, "Length of the trace window in hours")
+	GenerateCmd.Flags().Float64("requests-per-hour", 120, "Mean request rate of the trace")
+	GenerateCmd.Flags().Float64("jitter", 0.1, "Relative standard deviation of RTT samples")
+	GenerateCmd.Flags().Float64("diurnal", 0.2, "Relative swing of RTTs and the request rate over a day, in [0, 1)")
+	GenerateCmd.Flags().Int64("seed", 1, "Seed for the generator")
+	GenerateCmd.Flags().String("start", "2026-01-01T00:00:00Z", "Start of the trace window (RFC 3339)")
+	RegionsCmd.Flags().Bool("init", false, "Write the built-in region catalog to regions.json in the data directory")
+	MigrateCmd.Flags().Bool("dry-run", false, "Report what would be migrated without changing files")
+	ServeCmd.Flags().String("addr", ":8080", "Address to listen on")
+	ConsistencyServerCmd.Flags().String("addr", ":8081", "Address to listen on")
+	ConsistencyServerCmd.Flags().String("function-stats", "", "Function hit ratio file (default: function_consistency.json in the data directory)")
+	ConsistencyServerCmd.Flags().String("edge-stats", "", "Edge-function hit ratio file (default: edge_function_consistency.json in the data directory)")
+	ConsistencyServerCmd.Flags().String("auth-token", "", "Token required from clients (default: consistency.auth_token from config)")
 }
\ No newline at end of file
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"log"
+	"os"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 	"radsched/common"
 	"radsched/utils"
 	"fmt"
@@ -12,17 +16,90 @@ import (
 var RunCmd = &cobra.Command{
 	Use:   "run [function name]",
 	Short: "Run a specific function on the Rad-Sched scheduler",
-	Long:  "This command runs a specific function at the optimal edge location.",
-	Args:  cobra.ExactArgs(1), 
+	Long:  "This command runs a specific function at the optimal edge location. With --replay it instead repeats a logged decision from the seed, epsilon and candidates in the decision log, without changing any state.",
+	Args:  cobra.MaximumNArgs(1), 
 	Run: func(cmd *cobra.Command, args []string) {
+		if replay, _ := cmd.Flags().GetString("replay"); replay != "" {
+			replayDecision(replay)
+			return
+		}
+		if len(args) != 1 {
+			log.Fatalf("run needs a function name unless --replay is given")
+		}
 		functionName := strings.ToLower(args[0])
 		withWeight, _ := cmd.Flags().GetBool("with-weight")
-		RunFunction(functionName, withWeight)
+		schedule, _ := cmd.Flags().GetString("schedule")
+		if schedule != "" && !utils.IsSchedule(strings.ToUpper(schedule)) {
+			log.Fatalf("Unknown exploration schedule %s (expected one of %s)", schedule, strings.Join(utils.Schedules, ", "))
+		}
+		seed, _ := cmd.Flags().GetInt64("seed")
+		if !cmd.Flags().Changed("seed") {
+			seed = time.Now().UnixNano()
+		}
+		executionInfo := RunFunction(functionName, withWeight, utils.NewPolicyOptions(strings.ToUpper(schedule), seed))
+		if explain, _ := cmd.Flags().GetBool("explain"); explain {
+			printExplanation(executionInfo)
+		}
+		if invoke, _ := cmd.Flags().GetBool("invoke"); invoke {
+			result := invokeAndRecord(functionName, executionInfo.OptLocation, utils.ProviderInvoker{})
+			if err := utils.RecordDecisionOutcome(executionInfo.DecisionID, utils.DecisionOutcome{Latency: result.TotalRuntime}); err != nil {
+				log.Printf("Failed to record decision outcome: %v", err)
+			}
+		}
 	},
 }
 
+// Repeats a logged decision and reports whether it chose the logged location
+func replayDecision(id string) {
+	record, err := utils.FindDecision(id)
+	if err != nil {
+		log.Fatalf("Failed to replay decision: %v", err)
+	}
+	functions, err := utils.GetFunctionsAsMap()
+	if err != nil {
+		log.Fatalf("Failed to fetch function info: %v", err)
+	}
+	function, exists := functions[record.Function]
+	if !exists {
+		log.Fatalf("Function %s of decision %s is no longer registered", record.Function, id)
+	}
+	executionInfo, err := utils.ReplayDecision(function, record)
+	if err != nil {
+		log.Fatalf("Failed to replay decision %s: %v", id, err)
+	}
+
+	fmt.Printf("Function Name: %s\n", record.Function)
+	fmt.Printf("Policy: %s\n", record.Policy)
+	fmt.Printf("Optimal Location: %s\n", executionInfo.OptLocation)
+	fmt.Printf("Execution Time: %f\n", executionInfo.ExecutionTime)
+	if record.Policy == "weighted" {
+		fmt.Printf("Epsilon: %f (explored: %t)\n", executionInfo.Epsilon, executionInfo.Explored)
+		fmt.Printf("Seed: %d\n", executionInfo.Seed)
+	}
+	fmt.Printf("Logged Location: %s (matches: %t)\n", record.Location, record.Location == executionInfo.OptLocation)
+}
+
+// Invokes the function at the chosen location and adds the timing to its execution profile
+func invokeAndRecord(functionName string, location string, invoker utils.Invoker) utils.InvocationResult {
+	functions, err := utils.GetFunctionsAsMap()
+	if err != nil {
+		log.Fatalf("Failed to fetch function info: %v", err)
+	}
+	function := functions[functionName]
+	result, err := invoker.Invoke(function, location)
+	if err != nil {
+		log.Fatalf("Failed to invoke %s at %s: %v", functionName, location, err)
+	}
+	if err := utils.RecordExecution(function, result); err != nil {
+		log.Fatalf("Failed to record execution: %v", err)
+	}
+	fmt.Printf("Observed Execution Time: %f\n", result.ExecutionTime)
+	fmt.Printf("Observed Total Runtime: %f\n", result.TotalRuntime)
+	return result
+}
+
 // Run the function by choosing the optimal execution location
-func RunFunction(functionName string, withWeight bool) (common.ExecutionInfo) {
+func RunFunction(functionName string, withWeight bool, opts utils.PolicyOptions) (common.ExecutionInfo) {
 	// fetch function information
 	functions, err := utils.GetFunctionsAsMap()
 	if err != nil {
@@ -36,14 +113,74 @@ func RunFunction(functionName string, withWeight bool) (common.ExecutionInfo) {
 	// calculate optimal location
 	var executionInfo common.ExecutionInfo
 	if (withWeight) {
-		executionInfo = utils.RunOptWeightedLatency(function)
+		executionInfo, err = utils.RunOptWeightedLatency(function, opts)
 	} else {
-		executionInfo = utils.RunOptLatency(function)
+		executionInfo, err = utils.RunOptLatency(function)
+	}
+	if err != nil {
+		log.Fatalf("Failed to choose a location for %s: %v", functionName, err)
+	}
+
+	if err := utils.RecordPlacement(functionName, executionInfo.OptLocation); err != nil {
+		log.Printf("Failed to record placement: %v", err)
+	}
+	policy := "latency"
+	if (withWeight) {
+		policy = "weighted"
+	}
+	executionInfo.DecisionID, err = utils.RecordDecision(utils.NewDecisionRecord(time.Now(), functionName, "", policy, executionInfo))
+	if err != nil {
+		log.Printf("Failed to record decision: %v", err)
 	}
 
 	fmt.Printf("Function Name: %s\n", functionName)
 	fmt.Printf("Optimal Location: %s\n", executionInfo.OptLocation)
 	fmt.Printf("Execution Time: %f\n", executionInfo.ExecutionTime)
+	if executionInfo.Cost != "" {
+		fmt.Printf("Cost (USD): %s\n", executionInfo.Cost)
+	}
+	if (withWeight) {
+		fmt.Printf("Epsilon: %f (explored: %t)\n", executionInfo.Epsilon, executionInfo.Explored)
+		fmt.Printf("Seed: %d\n", executionInfo.Seed)
+	}
 
 	return executionInfo 
 }
+
+
+// Prints every candidate the policy considered and why it was or was not chosen
+func printExplanation(executionInfo common.ExecutionInfo) {
+	candidates := append([]common.CandidateInfo(nil), executionInfo.Candidates...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Location < candidates[j].Location
+	})
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "LOCATION\tCLIENT RTT\tEDGE RTT\tEXEC\tSTATE\tVALIDATION\tTRANSFER\tCOLD START\tESTIMATE\tWEIGHT\tSTATUS")
+	for _, candidate := range candidates {
+		status := candidate.Reason
+		if candidate.Filtered {
+			status = "filtered: " + candidate.Reason
+		} else if candidate.Location == executionInfo.OptLocation {
+			status = "chosen"
+		} else if status == "" {
+			status = "eligible"
+		}
+		edgeRTT, executionTime, stateTime, validation, transfer, coldStart, estimate := "-", "-", "-", "-", "-", "-", "-"
+		if !candidate.Filtered {
+			edgeRTT = fmt.Sprintf("%.2f", candidate.EdgeRTT)
+			executionTime = fmt.Sprintf("%.2f", candidate.ExecutionTime)
+			stateTime = fmt.Sprintf("%.2f", candidate.StateTime)
+			validation = fmt.Sprintf("%.2f", candidate.Validation)
+			transfer = fmt.Sprintf("%.2f", candidate.TransferTime)
+			coldStart = fmt.Sprintf("%.0f%% x %.2f", candidate.ColdProbability*100, candidate.ColdPenalty)
+			estimate = fmt.Sprintf("%.2f", candidate.Estimate)
+			if candidate.Uncertainty > 0 {
+				estimate = fmt.Sprintf("%.2f ±%.2f (%s)", candidate.Estimate, candidate.Uncertainty, candidate.RTTStatus)
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%.2f\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.2f\t%s\n",
+			candidate.Location, candidate.ClientRTT, edgeRTT, executionTime, stateTime, validation, transfer, coldStart, estimate, candidate.Weight, status)
+	}
+	writer.Flush()
+}
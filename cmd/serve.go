@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"log"
+	"net/http"
+	"github.com/spf13/cobra"
+	"radsched/utils"
+)
+
+var ServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Schedule functions for remote clients over HTTP",
+	Long:  "This command serves POST /schedule, choosing a location for each caller from the client RTT profile that matches it, POST /outcome for reporting what happened after a decision, and Prometheus metrics on GET /metrics.",
+	Args:  cobra.NoArgs,
+	Run:   runServe,
+}
+
+// Serves scheduling decisions until interrupted
+func runServe(cmd *cobra.Command, args []string) {
+	addr, _ := cmd.Flags().GetString("addr")
+
+	profiles, err := utils.LoadClientProfiles()
+	if err != nil {
+		log.Fatalf("Failed to load client profiles: %v", err)
+	}
+	geoip, err := utils.LoadConfiguredGeoIP()
+	if err != nil {
+		log.Fatalf("Failed to load GeoIP database: %v", err)
+	}
+	trustedProxies, err := utils.ParseTrustedProxies(utils.GetConfig().Serve.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Failed to parse serve.trusted_proxies: %v", err)
+	}
+	server := &utils.ScheduleServer{GeoIP: geoip, Metrics: utils.NewMetrics(), TrustedProxies: trustedProxies}
+
+	log.Printf("Serving schedule requests on %s with %d client profiles", addr, len(profiles))
+	if err := http.ListenAndServe(addr, server.Handler()); err != nil {
+		log.Fatalf("Schedule server failed: %v", err)
+	}
+}
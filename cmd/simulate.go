@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"radsched/utils"
+	"github.com/spf13/cobra"
+)
+
+var SimulateCmd = &cobra.Command{
+	Use:   "simulate [trace file]",
+	Short: "Replay a request trace against the scheduling policies offline",
+	Long:  "This command replays a JSON lines trace of {timestamp, function, client} requests against the latency and consistency data in the data directory, using the network snapshot current at each request's timestamp when the directory has them, and reports latency, inconsistency, cost and regret for each policy. The data files are not modified.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		policyNames, _ := cmd.Flags().GetStringSlice("policies")
+		schedule, _ := cmd.Flags().GetString("schedule")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		decisionLog, _ := cmd.Flags().GetString("decision-log")
+		if schedule != "" && !utils.IsSchedule(strings.ToUpper(schedule)) {
+			log.Fatalf("Unknown exploration schedule %s (expected one of %s)", schedule, strings.Join(utils.Schedules, ", "))
+		}
+
+		trace, err := utils.LoadTrace(args[0])
+		if err != nil {
+			log.Fatalf("Failed to load trace: %v", err)
+		}
+		var results []utils.SimulationResult
+		for _, name := range policyNames {
+			result, err := utils.Simulate(trace, name, strings.ToUpper(schedule), seed)
+			if err != nil {
+				log.Fatalf("Simulation of %s failed: %v", name, err)
+			}
+			results = append(results, result)
+		}
+		printSimulationResults(results)
+		if decisionLog != "" {
+			var decisions []utils.DecisionRecord
+			for _, result := range results {
+				decisions = append(decisions, result.Decisions...)
+			}
+			if err := utils.WriteDecisionLog(decisionLog, decisions); err != nil {
+				log.Fatalf("Failed to write decision log: %v", err)
+			}
+		}
+	},
+}
+
+func printSimulationResults(results []utils.SimulationResult) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "POLICY\tREQUESTS\tSKIPPED\tMEAN\tP50\tP99\tINCONSISTENT\tCOST (USD)\tEXPLORED\tMEAN REGRET")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f%%\t%.6f\t%d\t%.2f\n",
+			result.Policy, result.Requests, result.Skipped, result.MeanLatency, result.P50Latency, result.P99Latency,
+			result.InconsistencyRate*100, result.TotalCostUSD, result.Explorations, result.MeanRegret)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+	"github.com/spf13/cobra"
+	"radsched/utils"
+)
+
+var WarmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Keep likely edges warm for busy functions",
+	Long:  "This command sends lightweight warm-up invocations to the top-k locations each function is likely to be placed at, based on recent placements and forecasted traffic, within an hourly budget.",
+	Args:  cobra.NoArgs,
+	Run:   runWarmer,
+}
+
+// Runs warmer rounds until interrupted, or once with --once
+func runWarmer(cmd *cobra.Command, args []string) {
+	cfg := utils.GetConfig().Warmer
+	once, _ := cmd.Flags().GetBool("once")
+	statsOnly, _ := cmd.Flags().GetBool("stats")
+	topK, _ := cmd.Flags().GetInt("top-k")
+	budget, _ := cmd.Flags().GetInt("budget")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	if !cmd.Flags().Changed("top-k") {
+		topK = cfg.TopK
+	}
+	if !cmd.Flags().Changed("budget") {
+		budget = cfg.BudgetPerHour
+	}
+	if !cmd.Flags().Changed("interval") {
+		interval = time.Duration(cfg.IntervalSeconds) * time.Second
+	}
+	if interval <= 0 {
+		log.Fatalf("--interval must be positive, got %v", interval)
+	}
+
+	if statsOnly {
+		printWarmerStats()
+		return
+	}
+
+	warmer := &utils.Warmer{
+		Invoker:       utils.ProviderInvoker{},
+		TopK:          topK,
+		BudgetPerHour: budget,
+		Interval:      interval,
+	}
+	for {
+		functions, err := utils.GetFunctionsAsMap()
+		if err != nil {
+			log.Fatalf("Failed to fetch function info: %v", err)
+		}
+		round, err := warmer.RunOnce(functions)
+		if err != nil {
+			log.Fatalf("Warmer round failed: %v", err)
+		}
+		for _, target := range round.Sent {
+			log.Printf("Warmed %s at %s (forecast %.2f/h)", target.Function, target.Location, target.Forecast)
+		}
+		for _, err := range round.Errors {
+			log.Printf("Warm-up failed: %v", err)
+		}
+		log.Printf("Round done: %d sent, %d still warm, budget exhausted: %t", len(round.Sent), round.StillWarm, round.BudgetExhausted)
+
+		if once {
+			printWarmerStats()
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Prints warm-ups sent and the warm-hit rate of invocations that followed a warm-up
+func printWarmerStats() {
+	state, err := utils.LoadWarmerState()
+	if err != nil {
+		log.Fatalf("Failed to load warmer state: %v", err)
+	}
+	profiles, err := utils.LoadExecutionProfiles()
+	if err != nil {
+		log.Fatalf("Failed to load execution profiles: %v", err)
+	}
+
+	fmt.Printf("Warm-ups Sent: %d (errors: %d, budget used this hour: %d)\n", state.WarmupsSent, state.WarmupErrors, state.BudgetSpent)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "FUNCTION\tLOCATION\tWARM HITS\tCOLD MISSES\tWARM-HIT RATE")
+	totalHits, totalMisses := 0, 0
+	functions := make([]string, 0, len(profiles))
+	for function := range profiles {
+		functions = append(functions, function)
+	}
+	sort.Strings(functions)
+	for _, function := range functions {
+		locations := make([]string, 0, len(profiles[function]))
+		for location := range profiles[function] {
+			locations = append(locations, location)
+		}
+		sort.Strings(locations)
+		for _, location := range locations {
+			profile := profiles[function][location]
+			if profile.WarmedHits+profile.WarmedMisses == 0 {
+				continue
+			}
+			totalHits += profile.WarmedHits
+			totalMisses += profile.WarmedMisses
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%.1f%%\n", function, location, profile.WarmedHits, profile.WarmedMisses,
+				100*float64(profile.WarmedHits)/float64(profile.WarmedHits+profile.WarmedMisses))
+		}
+	}
+	writer.Flush()
+	if totalHits+totalMisses > 0 {
+		fmt.Printf("Overall Warm-Hit Rate: %.1f%%\n", 100*float64(totalHits)/float64(totalHits+totalMisses))
+	}
+}
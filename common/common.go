@@ -1,10 +1,53 @@
 package common
 
 type FunctionInfo struct {
-	FunctionName  string `json:"function_name"`
-	ExecutionTime string `json:"execution_time"`
-	FunctionURL   string `json:"function_url"`
-	Datacenter    string `json:"datacenter"`
+	SchemaVersion int                   `json:"schema_version,omitempty"`
+	FunctionName  string                `json:"function_name"`
+	ExecutionTime Duration              `json:"execution_time"`
+	MemoryMB      int                   `json:"memory_mb,omitempty"`
+	Runtime       string                `json:"runtime,omitempty"`
+	FunctionURL   string                `json:"function_url"`
+	Datacenter    string                `json:"datacenter"`
+	Payload       PayloadEstimate       `json:"payload"`
+	StateKeys     []string              `json:"state_keys,omitempty"`
+	StateAccess   StateAccessProfile    `json:"state_access"`
+	Owner         string                `json:"owner,omitempty"`
+	Tags          []string              `json:"tags,omitempty"`
+	Constraints   *PlacementConstraints `json:"constraints,omitempty"`
+}
+
+// How an invocation uses primary state: the number of sequential reads and
+// writes, and the fraction of them that are writes
+type StateAccessProfile struct {
+	SequentialAccesses int     `json:"sequential_accesses"` // 0 means unset, treated as a single read
+	WriteFraction      float64 `json:"write_fraction"`
+}
+
+// Sequential state accesses per invocation
+func (p StateAccessProfile) Accesses() int {
+	if p.SequentialAccesses <= 0 {
+		return 1
+	}
+	return p.SequentialAccesses
+}
+
+// Expected sequential writes per invocation
+func (p StateAccessProfile) Writes() float64 {
+	return float64(p.Accesses()) * p.WriteFraction
+}
+
+// Estimated request and response body sizes of one invocation
+type PayloadEstimate struct {
+	RequestBytes  int64 `json:"request_bytes"`
+	ResponseBytes int64 `json:"response_bytes"`
+}
+
+// Where a function may be placed; every policy enforces these
+type PlacementConstraints struct {
+	AllowedRegions       []string `json:"allowed_regions,omitempty"`
+	AllowedJurisdictions []string `json:"allowed_jurisdictions,omitempty"`
+	ExcludedEdges        []string `json:"excluded_edges,omitempty"`
+	RequiredTags         []string `json:"required_tags,omitempty"`
 }
 
 type LocationInfo struct {
@@ -15,9 +58,37 @@ type LocationInfo struct {
 type ExecutionInfo struct {
 	OptLocation   string
 	ExecutionTime float64
-	Cost          string
+	Cost          string  // USD per invocation at OptLocation, from the region catalog
+	Explored      bool    // chosen by an exploration step
+	Epsilon       float64 // exploration rate used for the decision
+	Seed          int64   // seed of the random source, for replay
+	Propensity    float64 // probability the policy had of choosing OptLocation
+	DecisionID    string  // ID of the decision in the audit log, if it was logged
+	Candidates    []CandidateInfo
 }
 
+// A location a policy considered, with its estimate or the reason it was dropped
+type CandidateInfo struct {
+	Location        string
+	ClientRTT       float64
+	EdgeRTT         float64
+	ExecutionTime   float64 // declared or learned execution time at this location
+	StateTime       float64 // round trips of sequential writes to primary state
+	Validation      float64 // round trip validating speculative reads
+	TransferTime    float64 // request and response transfer over the client link
+	RTTStatus       string  // least certain of the client and edge links: measured, imputed, unreachable or unknown
+	Uncertainty     float64 // ms of uncertainty in the imputed RTTs
+	ColdProbability float64 // chance the location has no warm instance
+	ColdPenalty     float64 // cold start cost in ms if it has none
+	Estimate        float64
+	Weight          float64 // consistency failure weight, weighted policy only
+	Filtered        bool
+	Eligible        bool // could be chosen: not filtered and faster than the datacenter
+	Reason          string
+}
+
+// Built-in region lists and metadata, used to build the default region catalog
+
 var All_Datacenters = []string{
 	"us-east-1", "us-east-2", "us-west-1", "us-west-2",
 	"ca-central-1", "ca-west-1", "eu-west-1", "eu-west-2", "eu-west-3",
@@ -41,6 +112,44 @@ var Datacenters = []string{
 	"ap-northeast-3",
 }
 
+// Legal jurisdiction each region's data resides in
+var RegionJurisdictions = map[string]string{
+	"us-east-1": "us", "us-east-2": "us", "us-west-1": "us", "us-west-2": "us",
+	"us-gov-east-1": "us", "us-gov-west-1": "us",
+	"ca-central-1": "ca", "ca-west-1": "ca",
+	"eu-west-1": "eu", "eu-west-3": "eu", "eu-central-1": "eu", "eu-south-1": "eu",
+	"eu-south-2": "eu", "eu-north-1": "eu",
+	"eu-west-2": "uk", "eu-central-2": "ch",
+	"il-central-1": "il", "me-south-1": "bh", "me-central-1": "ae", "af-south-1": "za",
+	"ap-east-1": "hk", "ap-south-1": "in", "ap-south-2": "in",
+	"ap-northeast-1": "jp", "ap-northeast-3": "jp", "ap-northeast-2": "kr",
+	"ap-southeast-1": "sg", "ap-southeast-2": "au", "ap-southeast-4": "au",
+	"ap-southeast-3": "id", "sa-east-1": "br",
+	"cn-north-1": "cn", "cn-northwest-1": "cn",
+}
+
+// A point on the earth in degrees
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Approximate location of each region's datacenters
+var RegionCoordinates = map[string]GeoPoint{
+	"us-east-1": {38.9, -77.4}, "us-east-2": {40.0, -83.0}, "us-west-1": {37.4, -121.9}, "us-west-2": {45.8, -119.7},
+	"us-gov-east-1": {40.0, -83.0}, "us-gov-west-1": {45.8, -119.7},
+	"ca-central-1": {45.5, -73.6}, "ca-west-1": {51.0, -114.1},
+	"eu-west-1": {53.3, -6.3}, "eu-west-2": {51.5, -0.1}, "eu-west-3": {48.9, 2.4},
+	"eu-central-1": {50.1, 8.7}, "eu-central-2": {47.4, 8.5}, "eu-south-1": {45.5, 9.2},
+	"eu-south-2": {41.6, -0.9}, "eu-north-1": {59.3, 18.1},
+	"il-central-1": {32.1, 34.8}, "me-south-1": {26.1, 50.6}, "me-central-1": {25.2, 55.3}, "af-south-1": {-33.9, 18.4},
+	"ap-east-1": {22.3, 114.2}, "ap-south-1": {19.1, 72.9}, "ap-south-2": {17.4, 78.5},
+	"ap-northeast-1": {35.7, 139.7}, "ap-northeast-2": {37.6, 127.0}, "ap-northeast-3": {34.7, 135.5},
+	"ap-southeast-1": {1.35, 103.8}, "ap-southeast-2": {-33.9, 151.2}, "ap-southeast-3": {-6.2, 106.8},
+	"ap-southeast-4": {-37.8, 145.0}, "sa-east-1": {-23.5, -46.6},
+	"cn-north-1": {39.9, 116.4}, "cn-northwest-1": {37.2, 106.2},
+}
+
 var TEST_FUNCTION_MAP = map[string]float64{
     "function1" : 5,
     "function2" : 25,
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Current version of the FunctionInfo schema
+const FunctionSchemaVersion = 2
+
+const (
+	minMemoryMB = 128
+	maxMemoryMB = 10240
+)
+
+// Duration that reads "100ms"/"1.5s" strings, and bare numbers as milliseconds,
+// and is written back as a duration string
+type Duration time.Duration
+
+func ParseDuration(value string) (Duration, error) {
+	value = strings.TrimSpace(value)
+	if ms, err := strconv.ParseFloat(value, 64); err == nil {
+		return Duration(ms * float64(time.Millisecond)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return Duration(d), nil
+}
+
+func (d Duration) Milliseconds() float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var ms float64
+	if err := json.Unmarshal(data, &ms); err == nil {
+		*d = Duration(ms * float64(time.Millisecond))
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string or a number of milliseconds: %v", err)
+	}
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Checks that a function description is complete and consistent
+func (f FunctionInfo) Validate() error {
+	if f.FunctionName == "" || strings.ContainsAny(f.FunctionName, " \t\n/") {
+		return fmt.Errorf("invalid function name %q", f.FunctionName)
+	}
+	if f.ExecutionTime <= 0 {
+		return fmt.Errorf("execution time must be positive, got %s", f.ExecutionTime)
+	}
+	if strings.TrimSpace(f.Datacenter) == "" {
+		return fmt.Errorf("datacenter must not be empty")
+	}
+	if f.MemoryMB != 0 && (f.MemoryMB < minMemoryMB || f.MemoryMB > maxMemoryMB) {
+		return fmt.Errorf("memory must be between %d and %d MB, got %d", minMemoryMB, maxMemoryMB, f.MemoryMB)
+	}
+	if strings.ContainsAny(f.Runtime, " \t\n") {
+		return fmt.Errorf("invalid runtime %q", f.Runtime)
+	}
+	if f.FunctionURL != "" {
+		parsed, err := url.Parse(f.FunctionURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("function URL must be an absolute http(s) URL, got %q", f.FunctionURL)
+		}
+	}
+	if f.Payload.RequestBytes < 0 || f.Payload.ResponseBytes < 0 {
+		return fmt.Errorf("payload sizes must not be negative")
+	}
+	if f.StateAccess.SequentialAccesses < 0 {
+		return fmt.Errorf("sequential state accesses must not be negative")
+	}
+	if f.StateAccess.WriteFraction < 0 || f.StateAccess.WriteFraction > 1 {
+		return fmt.Errorf("write fraction must be between 0 and 1, got %v", f.StateAccess.WriteFraction)
+	}
+	for _, key := range f.StateKeys {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("state keys must not be empty")
+		}
+	}
+	return nil
+}
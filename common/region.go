@@ -0,0 +1,105 @@
+package common
+
+import (
+	"sort"
+	"strings"
+)
+
+// Price of running a function in a region
+type RegionPricing struct {
+	PerRequestUSD  float64 `json:"per_request_usd"`
+	PerGBSecondUSD float64 `json:"per_gb_second_usd"`
+}
+
+// A place functions can run, as described by the region catalog
+type Region struct {
+	Name         string            `json:"name"`               // the provider's name for the location
+	Provider     string            `json:"provider"`           // aws, http, mock or another registered provider
+	Endpoint     string            `json:"endpoint,omitempty"` // base URL of http locations
+	Options      map[string]string `json:"options,omitempty"`  // provider-specific settings
+	Location     *GeoPoint         `json:"location,omitempty"`
+	Jurisdiction string            `json:"jurisdiction,omitempty"`
+	Runtimes     []string          `json:"runtimes,omitempty"` // supported runtimes; empty means any
+	Pricing      *RegionPricing    `json:"pricing,omitempty"`
+	Edge         bool              `json:"edge"`    // hosts Radical edges
+	Primary      bool              `json:"primary"` // hosts Radical primary datacenters
+	Probed       bool              `json:"probed"`  // measured by bootstrap
+	Tags         []string          `json:"tags,omitempty"`
+}
+
+// Identifies the location across providers. AWS regions keep their bare
+// name; other providers' locations are "<provider>:<name>".
+func (r Region) ID() string {
+	if r.Provider == "" || r.Provider == "aws" {
+		return r.Name
+	}
+	return r.Provider + ":" + r.Name
+}
+
+// Whether the region runs the given runtime
+func (r Region) SupportsRuntime(runtime string) bool {
+	if len(r.Runtimes) == 0 || runtime == "" {
+		return true
+	}
+	for _, supported := range r.Runtimes {
+		if strings.EqualFold(supported, runtime) {
+			return true
+		}
+	}
+	return false
+}
+
+type RegionCatalog []Region
+
+// Looks up a region by its ID
+func (catalog RegionCatalog) Get(name string) (Region, bool) {
+	for _, region := range catalog {
+		if region.ID() == name {
+			return region, true
+		}
+	}
+	return Region{}, false
+}
+
+// IDs of the regions bootstrap measures, sorted
+func (catalog RegionCatalog) Probed() []string {
+	var names []string
+	for _, region := range catalog {
+		if region.Probed {
+			names = append(names, region.ID())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AWS Lambda x86 list prices
+var defaultPricing = RegionPricing{PerRequestUSD: 0.0000002, PerGBSecondUSD: 0.0000166667}
+
+// Catalog used when no regions file exists: every AWS region hosts primaries,
+// and the regions in Datacenters host edges and are probed
+func DefaultRegionCatalog() RegionCatalog {
+	probed := make(map[string]bool)
+	for _, region := range Datacenters {
+		probed[region] = true
+	}
+	catalog := make(RegionCatalog, 0, len(All_Datacenters))
+	for _, name := range All_Datacenters {
+		pricing := defaultPricing
+		region := Region{
+			Name:         name,
+			Provider:     "aws",
+			Jurisdiction: RegionJurisdictions[name],
+			Pricing:      &pricing,
+			Edge:         probed[name],
+			Primary:      true,
+			Probed:       probed[name],
+		}
+		if point, exists := RegionCoordinates[name]; exists {
+			region.Location = &point
+		}
+		catalog = append(catalog, region)
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog
+}
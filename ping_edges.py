@@ -1,9 +1,20 @@
 import subprocess
 import re
 import json
+import sys
+from urllib.parse import urlparse
 
-def ping_region(region):
-    url = f"ec2.{region}.amazonaws.com"  # EC2 URL
+DEFAULT_REGIONS = ["us-west-1", "us-east-1", "us-west-2", "us-east-2", "ap-east-1",
+                   "ap-south-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3"]
+
+def region_host(region, endpoint=None):
+    if endpoint:
+        # accept a bare host as well as a URL
+        return urlparse(endpoint).hostname or endpoint
+    return f"ec2.{region}.amazonaws.com"  # EC2 URL
+
+def ping_region(region, endpoint=None):
+    url = region_host(region, endpoint)
     try:
         result = subprocess.run(["/sbin/ping", "-c", "1", url], capture_output=True, text=True)
         match = re.search(r"time=([\d.]+)\s*ms", result.stdout)
@@ -12,19 +23,25 @@ def ping_region(region):
         if rtt is not None:
             return rtt
         else:
-            print(f"Received no RTT data from {url}")
+            print(f"Received no RTT data from {url}", file=sys.stderr)
             return None
     except Exception as e:
-        print(f"Failed to ping {url}: {e}")
+        print(f"Failed to ping {url}: {e}", file=sys.stderr)
         return None
 
-def ping_regions():
-    regions = ["us-west-1", "us-east-1", "us-west-2", "us-east-2", "ap-east-1",
-               "ap-south-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3"]
-    
+# Each argument is a region name, or name=endpoint to ping the endpoint's host
+# instead of the region's EC2 host. Without arguments the default regions are pinged.
+def parse_regions(args):
+    regions = []
+    for arg in args or DEFAULT_REGIONS:
+        name, _, endpoint = arg.partition("=")
+        regions.append((name, endpoint or None))
+    return regions
+
+def ping_regions(regions):
     rtt_data = {}
-    for region in regions:
-        rtt = ping_region(region)
+    for region, endpoint in regions:
+        rtt = ping_region(region, endpoint)
         if rtt is not None:
             rtt_data[region] = rtt
         else:
@@ -33,5 +50,5 @@ def ping_regions():
     return rtt_data
 
 if __name__ == "__main__":
-    data = ping_regions()
+    data = ping_regions(parse_regions(sys.argv[1:]))
     print(json.dumps(data, indent=4))
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
-	"strconv"
 	"strings"
 
 	"radsched/cmd"
@@ -32,8 +31,8 @@ func algorithm_test() {
 		}
 		clientDatacenter1Time := datacenterMap[function1.Datacenter]
 		clientDatacenter2Time := datacenterMap[function2.Datacenter]
-		function1ExuctionTime, err := strconv.ParseFloat(strings.Split(function1.ExecutionTime, "m")[0], 64)
-		function2ExuctionTime, err := strconv.ParseFloat(strings.Split(function2.ExecutionTime, "m")[0], 64)
+		function1ExuctionTime := function1.ExecutionTime.Milliseconds()
+		function2ExuctionTime := function2.ExecutionTime.Milliseconds()
 	
 		function1DatacenterRuntime := clientDatacenter1Time + function1ExuctionTime
 		function2DatacenterRuntime := clientDatacenter2Time + function2ExuctionTime
@@ -76,7 +75,7 @@ func opt_latency_test() {
 	// get radsched optimal locations
 	opt_locations := make([]string, len(TEST_FUNCTIONS))
 	for i := 0; i < len(TEST_FUNCTIONS); i++ {
-		radSchedResult := cmd.RunFunction(TEST_FUNCTIONS[i], false)
+		radSchedResult := cmd.RunFunction(TEST_FUNCTIONS[i], false, utils.PolicyOptions{})
 		opt_locations[i] = radSchedResult.OptLocation; 
 	}
 
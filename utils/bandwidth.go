@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"radsched/common"
+	"strings"
+	"time"
+)
+
+const ClientEdgeBandwidthFile = "client_edge_bandwidth.json"
+
+// Measures client -> region throughput in Mbps by downloading the configured
+// probe object from every region. Regions that fail are left out.
+func GetClientToEdgeBandwidth() (map[string]float64, error) {
+	cfg := GetConfig().Bandwidth
+	if cfg.ProbeURL == "" {
+		return nil, fmt.Errorf("no bandwidth.probe_url configured")
+	}
+
+	client := &http.Client{Timeout: time.Duration(cfg.ProbeTimeoutMs) * time.Millisecond}
+	bandwidth := make(map[string]float64)
+	for _, region := range GetRegionCatalog().Probed() {
+		url := strings.ReplaceAll(cfg.ProbeURL, "{region}", region)
+		mbps, err := measureThroughput(client, url)
+		if err != nil {
+			log.Printf("Failed to measure bandwidth to %s: %v", region, err)
+			continue
+		}
+		bandwidth[region] = mbps
+	}
+	return bandwidth, nil
+}
+
+// Throughput of one download, timed from the first byte so the RTT is excluded
+func measureThroughput(client *http.Client, url string) (float64, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	buffer := make([]byte, 32*1024)
+	first, err := resp.Body.Read(buffer)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	start := time.Now()
+	rest, err := io.CopyBuffer(io.Discard, resp.Body, buffer)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start).Seconds()
+	if rest == 0 || elapsed <= 0 {
+		return 0, fmt.Errorf("probe object of %d bytes is too small to measure", int64(first)+rest)
+	}
+	return float64(rest) * 8 / elapsed / 1e6, nil
+}
+
+func StoreBandwidth(bandwidth map[string]float64) error {
+	return writeDataFile(DataPath(ClientEdgeBandwidthFile), bandwidth)
+}
+
+// Measured client -> location throughput in Mbps; empty if never measured
+func GetBandwidth() (map[string]float64, error) {
+	bandwidth := make(map[string]float64)
+	_, err := readDataFile(DataPath(ClientEdgeBandwidthFile), &bandwidth)
+	if os.IsNotExist(err) {
+		return bandwidth, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bandwidth data: %v", err)
+	}
+	return bandwidth, nil
+}
+
+// Throughput to plan with for a link: configured override, then measurement, then default
+func linkBandwidth(location string, measured map[string]float64) float64 {
+	cfg := GetConfig().Bandwidth
+	if mbps, exists := cfg.Links[location]; exists && mbps > 0 {
+		return mbps
+	}
+	if mbps, exists := measured[location]; exists && mbps > 0 {
+		return mbps
+	}
+	return cfg.DefaultMbps
+}
+
+// Time in ms to move the function's request and response over a link. Only
+// the client link is charged: the registry has no size for the state an edge
+// validates and writes, so the edge to datacenter link pays round trips only.
+func transferTime(payload common.PayloadEstimate, mbps float64) float64 {
+	bytes := payload.RequestBytes + payload.ResponseBytes
+	if bytes == 0 || mbps <= 0 {
+		return 0
+	}
+	return float64(bytes) * 8 / (mbps * 1000)
+}
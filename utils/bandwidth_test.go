@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"radsched/common"
+)
+
+func TestTransferTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload common.PayloadEstimate
+		mbps    float64
+		want    float64
+	}{
+		// 1 MB both ways is 16 Mbit, 160 ms at 100 Mbps
+		{"request and response", common.PayloadEstimate{RequestBytes: 1e6, ResponseBytes: 1e6}, 100, 160},
+		{"request only", common.PayloadEstimate{RequestBytes: 125000}, 10, 100},
+		{"no payload", common.PayloadEstimate{}, 100, 0},
+		{"no bandwidth", common.PayloadEstimate{RequestBytes: 1e6}, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := transferTime(test.payload, test.mbps); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("got %v ms, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// A configured link wins over a measurement, which wins over the default
+func TestLinkBandwidth(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.Bandwidth.DefaultMbps = 50
+		cfg.Bandwidth.Links = map[string]float64{"eu-west-1": 200, "us-east-1": 0}
+	})
+	measured := map[string]float64{"eu-west-1": 80, "us-east-1": 120, "ap-south-1": 0}
+	tests := []struct {
+		location string
+		want     float64
+	}{
+		{"eu-west-1", 200},
+		{"us-east-1", 120}, // a zero override is ignored
+		{"ap-south-1", 50}, // so is a zero measurement
+		{"sa-east-1", 50},
+	}
+	for _, test := range tests {
+		if got := linkBandwidth(test.location, measured); got != test.want {
+			t.Errorf("%s: got %v Mbps, want %v", test.location, got, test.want)
+		}
+	}
+}
+
+// The transfer over the client link is part of every candidate estimate and
+// of the datacenter runtime
+func TestEstimatesChargeTransfer(t *testing.T) {
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	function := functions[0]
+	withConfig(t, func(cfg *Config) {
+		cfg.Bandwidth.Links = map[string]float64{function.Datacenter: 8}
+	})
+	measured, err := GetBandwidth()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(payload common.PayloadEstimate) (common.ExecutionInfo, common.ExecutionInfo) {
+		f := function
+		f.Payload = payload
+		edge, err := RunOptLatency(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		datacenter, err := RunDatacenterForClient(f, nil, PolicyOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return edge, datacenter
+	}
+	payload := common.PayloadEstimate{RequestBytes: 600, ResponseBytes: 400}
+	_, without := run(common.PayloadEstimate{})
+	edge, with := run(payload)
+	// 1000 bytes at 8 Mbps is 1 ms
+	if got := with.ExecutionTime - without.ExecutionTime; math.Abs(got-1) > 1e-9 {
+		t.Errorf("payload added %v ms to the datacenter, want 1", got)
+	}
+	if len(edge.Candidates) == 0 {
+		t.Fatal("no edge candidates")
+	}
+	for _, candidate := range edge.Candidates {
+		if want := transferTime(payload, linkBandwidth(candidate.Location, measured)); math.Abs(candidate.TransferTime-want) > 1e-9 {
+			t.Errorf("%s transfers in %v ms, want %v", candidate.Location, candidate.TransferTime, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"radsched/common"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Policy every benchmark is compared against
+const BenchBaseline = "datacenter"
+
+// One invocation made by the benchmark
+type BenchTrial struct {
+	Function  string  `json:"function"`
+	Policy    string  `json:"policy"`
+	Trial     int     `json:"trial"`
+	Location  string  `json:"location"`
+	Estimate  float64 `json:"estimate_ms"`
+	Observed  float64 `json:"observed_ms"` // total runtime measured by the caller
+	Execution float64 `json:"execution_ms"`
+	ColdStart *bool   `json:"cold_start,omitempty"`
+	Explored  bool    `json:"explored"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Observed latency of one policy for one function
+type BenchSummary struct {
+	Function string  `json:"function"`
+	Policy   string  `json:"policy"`
+	Trials   int     `json:"trials"`
+	Failures int     `json:"failures"`
+	Mean     float64 `json:"mean_ms"`
+	P50      float64 `json:"p50_ms"`
+	P95      float64 `json:"p95_ms"`
+	Min      float64 `json:"min_ms"`
+	Max      float64 `json:"max_ms"`
+	StdDev   float64 `json:"stddev_ms"`
+	Speedup  float64 `json:"speedup"` // baseline mean over this policy's mean, 0 if either is unknown
+}
+
+type BenchReport struct {
+	Trials    []BenchTrial   `json:"trials"`
+	Summaries []BenchSummary `json:"summaries"`
+}
+
+// Invokes each function trials times under each policy and the datacenter
+// baseline, from the bootstrap client. Policies run in turn within a trial so
+// they see the same conditions. Exploration and warmth are tracked in memory
+// and nothing is written to the data files.
+func RunBench(functions []common.FunctionInfo, policyNames []string, trials int, invoker Invoker, schedule string, seed int64) (BenchReport, error) {
+	var report BenchReport
+	names := []string{BenchBaseline}
+	for _, name := range policyNames {
+		if name != BenchBaseline {
+			names = append(names, name)
+		}
+	}
+	benchPolicies := make(map[string]Policy)
+	for _, name := range names {
+		policy, err := GetPolicy(name)
+		if err != nil {
+			return report, err
+		}
+		benchPolicies[name] = policy
+	}
+	clientRTTs, err := GetLocations()
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch client RTTs: %v", err)
+	}
+
+	state := NewPolicyState()
+	rng := rand.New(rand.NewSource(seed))
+	for _, function := range functions {
+		for trial := 1; trial <= trials; trial++ {
+			for _, name := range names {
+				now := time.Now()
+				decision, err := benchPolicies[name](function, clientRTTs, PolicyOptions{Schedule: schedule, Seed: seed, Rand: rng, Now: now, State: state})
+				if err != nil {
+					return report, fmt.Errorf("%s policy failed for %s: %v", name, function.FunctionName, err)
+				}
+				result := BenchTrial{
+					Function: function.FunctionName,
+					Policy:   name,
+					Trial:    trial,
+					Location: decision.OptLocation,
+					Estimate: decision.ExecutionTime,
+					Explored: decision.Explored,
+				}
+				invocation, err := invoker.Invoke(function, decision.OptLocation)
+				if err != nil {
+					result.Error = err.Error()
+				} else {
+					result.Observed = invocation.TotalRuntime
+					result.Execution = invocation.ExecutionTime
+					result.ColdStart = invocation.ColdStart
+				}
+				state.RecordInvocation(function.FunctionName, decision.OptLocation, now)
+				report.Trials = append(report.Trials, result)
+			}
+		}
+	}
+	report.Summaries = summarizeBench(report.Trials)
+	return report, nil
+}
+
+// Summaries per function and policy, in the order they were first run
+func summarizeBench(trials []BenchTrial) []BenchSummary {
+	type key struct{ function, policy string }
+	var order []key
+	observed := make(map[key][]float64)
+	failures := make(map[key]int)
+	for _, trial := range trials {
+		k := key{trial.Function, trial.Policy}
+		if _, seen := observed[k]; !seen {
+			order = append(order, k)
+			observed[k] = nil
+		}
+		if trial.Error != "" {
+			failures[k]++
+			continue
+		}
+		observed[k] = append(observed[k], trial.Observed)
+	}
+
+	var summaries []BenchSummary
+	for _, k := range order {
+		values := observed[k]
+		summary := BenchSummary{Function: k.function, Policy: k.policy, Trials: len(values) + failures[k], Failures: failures[k]}
+		if len(values) > 0 {
+			sorted := append([]float64(nil), values...)
+			sort.Float64s(sorted)
+			summary.Mean = Mean(values)
+			summary.P50 = Quantile(values, 0.5)
+			summary.P95 = Quantile(values, 0.95)
+			summary.Min = sorted[0]
+			summary.Max = sorted[len(sorted)-1]
+			var squares float64
+			for _, value := range values {
+				squares += (value - summary.Mean) * (value - summary.Mean)
+			}
+			summary.StdDev = math.Sqrt(squares / float64(len(values)))
+		}
+		summaries = append(summaries, summary)
+	}
+
+	baselines := make(map[string]float64)
+	for _, summary := range summaries {
+		if summary.Policy == BenchBaseline {
+			baselines[summary.Function] = summary.Mean
+		}
+	}
+	for i := range summaries {
+		if baseline := baselines[summaries[i].Function]; baseline > 0 && summaries[i].Mean > 0 {
+			summaries[i].Speedup = baseline / summaries[i].Mean
+		}
+	}
+	return summaries
+}
+
+// Writes the per-trial results as CSV
+func WriteBenchTrialsCSV(w io.Writer, trials []BenchTrial) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"function", "policy", "trial", "location", "estimate_ms", "observed_ms", "execution_ms", "cold_start", "explored", "error"})
+	for _, trial := range trials {
+		coldStart := ""
+		if trial.ColdStart != nil {
+			coldStart = strconv.FormatBool(*trial.ColdStart)
+		}
+		writer.Write([]string{
+			trial.Function, trial.Policy, strconv.Itoa(trial.Trial), trial.Location,
+			formatMs(trial.Estimate), formatMs(trial.Observed), formatMs(trial.Execution),
+			coldStart, strconv.FormatBool(trial.Explored), trial.Error,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the summaries as CSV
+func WriteBenchSummaryCSV(w io.Writer, summaries []BenchSummary) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"function", "policy", "trials", "failures", "mean_ms", "p50_ms", "p95_ms", "min_ms", "max_ms", "stddev_ms", "speedup"})
+	for _, summary := range summaries {
+		writer.Write([]string{
+			summary.Function, summary.Policy, strconv.Itoa(summary.Trials), strconv.Itoa(summary.Failures),
+			formatMs(summary.Mean), formatMs(summary.P50), formatMs(summary.P95), formatMs(summary.Min),
+			formatMs(summary.Max), formatMs(summary.StdDev), strconv.FormatFloat(summary.Speedup, 'f', 3, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatMs(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', 2, 64)
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"radsched/common"
+)
+
+// Answers 100 ms at the function's datacenter and 40 ms elsewhere, and fails at one location
+type benchInvoker struct {
+	datacenters map[string]string
+	failAt      string
+}
+
+func (invoker benchInvoker) Invoke(function common.FunctionInfo, location string) (InvocationResult, error) {
+	if location == invoker.failAt {
+		return InvocationResult{}, errors.New("unavailable")
+	}
+	if location == invoker.datacenters[function.FunctionName] {
+		return InvocationResult{Location: location, TotalRuntime: 100, ExecutionTime: 60}, nil
+	}
+	return InvocationResult{Location: location, TotalRuntime: 40, ExecutionTime: 20}, nil
+}
+
+func (invoker benchInvoker) Warm(function common.FunctionInfo, location string) error {
+	return nil
+}
+
+func TestSummarizeBench(t *testing.T) {
+	trials := []BenchTrial{
+		{Function: "f", Policy: "datacenter", Observed: 100},
+		{Function: "f", Policy: "latency", Observed: 40},
+		{Function: "f", Policy: "datacenter", Observed: 200},
+		{Function: "f", Policy: "latency", Observed: 60},
+		{Function: "f", Policy: "datacenter", Error: "unavailable"},
+		{Function: "f", Policy: "latency", Observed: 50},
+		{Function: "g", Policy: "latency", Observed: 10},
+	}
+	want := []BenchSummary{
+		{Function: "f", Policy: "datacenter", Trials: 3, Failures: 1, Mean: 150, P50: 150, P95: 195, Min: 100, Max: 200, StdDev: 50, Speedup: 1},
+		{Function: "f", Policy: "latency", Trials: 3, Mean: 50, P50: 50, P95: 59, Min: 40, Max: 60, StdDev: math.Sqrt(200.0 / 3), Speedup: 3},
+		// no baseline for g, so no speedup
+		{Function: "g", Policy: "latency", Trials: 1, Mean: 10, P50: 10, P95: 10, Min: 10, Max: 10},
+	}
+	got := summarizeBench(trials)
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(roundSummary(got[i]), roundSummary(want[i])) {
+			t.Errorf("summary %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	var csv bytes.Buffer
+	if err := WriteBenchSummaryCSV(&csv, got); err != nil {
+		t.Fatal(err)
+	}
+	if line := strings.Split(csv.String(), "\n")[1]; line != "f,datacenter,3,1,150.00,150.00,195.00,100.00,200.00,50.00,1.000" {
+		t.Errorf("CSV row %q", line)
+	}
+}
+
+func roundSummary(summary BenchSummary) BenchSummary {
+	for _, value := range []*float64{&summary.Mean, &summary.P50, &summary.P95, &summary.Min, &summary.Max, &summary.StdDev, &summary.Speedup} {
+		*value = math.Round(*value*1e6) / 1e6
+	}
+	return summary
+}
+
+// Every policy runs in each trial after the baseline, and the benchmark leaves
+// the data files alone
+func TestRunBench(t *testing.T) {
+	withDataCopy(t)
+	before := dataFiles(t)
+	all, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	functions := all[:2]
+	invoker := benchInvoker{datacenters: make(map[string]string)}
+	for _, function := range functions {
+		invoker.datacenters[function.FunctionName] = function.Datacenter
+	}
+
+	report, err := RunBench(functions, []string{"weighted", "datacenter", "latency"}, 3, invoker, INVERSE_N, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Trials) != 2*3*3 {
+		t.Fatalf("%d trials, want 18", len(report.Trials))
+	}
+	for i, trial := range report.Trials {
+		if want := []string{"datacenter", "weighted", "latency"}[i%3]; trial.Policy != want {
+			t.Errorf("trial %d ran %s, want %s", i, trial.Policy, want)
+		}
+		if trial.Policy == BenchBaseline && (trial.Location != invoker.datacenters[trial.Function] || trial.Observed != 100) {
+			t.Errorf("baseline trial %+v, want the datacenter at 100 ms", trial)
+		}
+	}
+	if len(report.Summaries) != 2*3 {
+		t.Fatalf("%d summaries, want 6", len(report.Summaries))
+	}
+	for _, summary := range report.Summaries {
+		if summary.Trials != 3 || summary.Speedup != 100/summary.Mean {
+			t.Errorf("summary %+v, want 3 trials and a speedup over 100 ms", summary)
+		}
+	}
+	if after := dataFiles(t); !reflect.DeepEqual(before, after) {
+		t.Error("the benchmark changed the data files")
+	}
+
+	// failed invocations are reported rather than ending the benchmark
+	invoker.failAt = functions[0].Datacenter
+	report, err = RunBench(functions[:1], nil, 2, invoker, INVERSE_N, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := report.Summaries[0]; summary.Failures != 2 || summary.Speedup != 0 || report.Trials[0].Error == "" {
+		t.Errorf("summary %+v, want two failures and no speedup", summary)
+	}
+
+	if _, err := RunBench(functions, []string{"fastest"}, 1, invoker, INVERSE_N, 1); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+// Contents of the files in the data directory
+func dataFiles(t *testing.T) map[string]string {
+	files := make(map[string]string)
+	entries, err := os.ReadDir(GetConfig().DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(GetConfig().DataDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"fmt"
+	"radsched/common"
+	"strings"
+)
+
+// Returns a new map holding only the edges the function may run at, and the
+// locations that were dropped with the reason. The function's primary
+// datacenter is never an edge candidate; the policies estimate it separately.
+// The input map is left untouched.
+func FilterCandidates(function common.FunctionInfo, locations map[string]float64) (map[string]float64, []common.CandidateInfo) {
+	cfg := GetConfig()
+	ruleSets := []CandidateRules{cfg.Candidates.CandidateRules}
+	if rules, exists := cfg.Candidates.Functions[strings.ToLower(function.FunctionName)]; exists {
+		ruleSets = append(ruleSets, rules)
+	}
+
+	candidates := make(map[string]float64)
+	var filtered []common.CandidateInfo
+	for _, location := range sortedKeys(locations) {
+		if location == function.Datacenter {
+			continue
+		}
+		reason := catalogViolation(function, location)
+		if reason == "" {
+			reason = ConstraintViolation(function.Constraints, location)
+		}
+		for _, rules := range ruleSets {
+			if reason != "" {
+				break
+			}
+			reason = rules.reject(location)
+		}
+		if reason != "" {
+			filtered = append(filtered, common.CandidateInfo{
+				Location:  location,
+				ClientRTT: locations[location],
+				Filtered:  true,
+				Reason:    reason,
+			})
+			continue
+		}
+		candidates[location] = locations[location]
+	}
+	return candidates, filtered
+}
+
+// Reason the region catalog rules a location out for the function, or "" if it can host it
+func catalogViolation(function common.FunctionInfo, location string) string {
+	region, known := GetRegionCatalog().Get(location)
+	if !known {
+		return "not in region catalog"
+	}
+	if !region.Edge {
+		return "does not host edges"
+	}
+	if !region.SupportsRuntime(function.Runtime) {
+		return fmt.Sprintf("runtime %s not supported", function.Runtime)
+	}
+	return ""
+}
+
+// Reason a function's placement constraints forbid a location, or "" if allowed
+func ConstraintViolation(constraints *common.PlacementConstraints, location string) string {
+	if constraints == nil {
+		return ""
+	}
+	if containsFold(constraints.ExcludedEdges, location) {
+		return "excluded edge"
+	}
+	if len(constraints.AllowedRegions) > 0 && !containsFold(constraints.AllowedRegions, location) {
+		return "region not allowed"
+	}
+	if len(constraints.AllowedJurisdictions) > 0 {
+		region, known := GetRegionCatalog().Get(location)
+		jurisdiction := region.Jurisdiction
+		if !known || jurisdiction == "" {
+			return "unknown jurisdiction"
+		}
+		if !containsFold(constraints.AllowedJurisdictions, jurisdiction) {
+			return fmt.Sprintf("jurisdiction %s not allowed", jurisdiction)
+		}
+	}
+	for _, tag := range constraints.RequiredTags {
+		if !containsFold(regionTags(location), tag) {
+			return fmt.Sprintf("missing required tag %s", tag)
+		}
+	}
+	return ""
+}
+
+// Reason the rules reject a location, or "" if it is allowed
+func (rules CandidateRules) reject(location string) string {
+	if containsFold(rules.Deny, location) {
+		return "denied by candidate rules"
+	}
+	if len(rules.Allow) > 0 && !containsFold(rules.Allow, location) {
+		return "not in candidate allow list"
+	}
+	for _, tag := range rules.Tags {
+		if !containsFold(regionTags(location), tag) {
+			return fmt.Sprintf("missing tag %s", tag)
+		}
+	}
+	return ""
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"radsched/common"
+)
+
+// The primary datacenter is estimated on its own, never as an edge candidate
+func TestFilterCandidatesSkipsDatacenter(t *testing.T) {
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, function := range functions {
+		candidates, filtered := FilterCandidates(function, locations)
+		if _, exists := candidates[function.Datacenter]; exists {
+			t.Errorf("%s: datacenter %s is an edge candidate", function.FunctionName, function.Datacenter)
+		}
+		for _, candidate := range filtered {
+			if candidate.Location == function.Datacenter {
+				t.Errorf("%s: datacenter %s is listed as a filtered edge", function.FunctionName, function.Datacenter)
+			}
+		}
+		decision, err := RunOptLatencyForClient(function, locations, PolicyOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, candidate := range decision.Candidates {
+			if candidate.Location == function.Datacenter {
+				t.Errorf("%s: datacenter %s is explained as an edge", function.FunctionName, function.Datacenter)
+			}
+		}
+	}
+}
+
+func TestFilterCandidatesRules(t *testing.T) {
+	regions := GetRegionCatalog().Probed()
+	function := common.FunctionInfo{FunctionName: "rules", Datacenter: regions[0]}
+	edges := regions[1:4]
+	locations := make(map[string]float64)
+	for _, edge := range edges {
+		locations[edge] = 10
+	}
+
+	tests := []struct {
+		name       string
+		candidates CandidatesConfig
+		regionTags map[string][]string
+		want       []string          // candidates left
+		reasons    map[string]string // filtered location -> reason
+	}{
+		{name: "no rules", want: edges},
+		{
+			name:       "global deny",
+			candidates: CandidatesConfig{CandidateRules: CandidateRules{Deny: []string{edges[0]}}},
+			want:       edges[1:],
+			reasons:    map[string]string{edges[0]: "denied by candidate rules"},
+		},
+		{
+			name:       "global allow",
+			candidates: CandidatesConfig{CandidateRules: CandidateRules{Allow: []string{strings.ToUpper(edges[1])}}},
+			want:       edges[1:2],
+			reasons:    map[string]string{edges[0]: "not in candidate allow list", edges[2]: "not in candidate allow list"},
+		},
+		{
+			name: "function rules add to global rules",
+			candidates: CandidatesConfig{
+				CandidateRules: CandidateRules{Deny: []string{edges[0]}},
+				Functions:      map[string]CandidateRules{"rules": {Deny: []string{edges[1]}}},
+			},
+			want:    edges[2:],
+			reasons: map[string]string{edges[0]: "denied by candidate rules", edges[1]: "denied by candidate rules"},
+		},
+		{
+			name:       "other functions' rules do not apply",
+			candidates: CandidatesConfig{Functions: map[string]CandidateRules{"other": {Deny: edges}}},
+			want:       edges,
+		},
+		{
+			name:       "required tags",
+			candidates: CandidatesConfig{CandidateRules: CandidateRules{Tags: []string{"gpu"}}},
+			regionTags: map[string][]string{edges[2]: {"GPU"}},
+			want:       edges[2:],
+			reasons:    map[string]string{edges[0]: "missing tag gpu", edges[1]: "missing tag gpu"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withConfig(t, func(cfg *Config) {
+				cfg.Candidates, cfg.RegionTags = test.candidates, test.regionTags
+			})
+			candidates, filtered := FilterCandidates(function, locations)
+			if got := sortedKeys(candidates); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("candidates %v, want %v", got, test.want)
+			}
+			reasons := make(map[string]string)
+			for _, candidate := range filtered {
+				reasons[candidate.Location] = candidate.Reason
+			}
+			if len(reasons) != len(test.reasons) || (len(reasons) > 0 && !reflect.DeepEqual(reasons, test.reasons)) {
+				t.Errorf("filtered %v, want %v", reasons, test.reasons)
+			}
+			if len(locations) != len(edges) {
+				t.Errorf("the input map was changed to %v", locations)
+			}
+		})
+	}
+}
+
+func TestConstraintViolation(t *testing.T) {
+	catalog := GetRegionCatalog()
+	location := catalog.Probed()[1]
+	region, _ := catalog.Get(location)
+	tests := []struct {
+		name        string
+		constraints *common.PlacementConstraints
+		location    string
+		regionTags  map[string][]string
+		want        string
+	}{
+		{"no constraints", nil, location, nil, ""},
+		{"excluded edge", &common.PlacementConstraints{ExcludedEdges: []string{strings.ToUpper(location)}}, location, nil, "excluded edge"},
+		{"allowed region", &common.PlacementConstraints{AllowedRegions: []string{location}}, location, nil, ""},
+		{"region not allowed", &common.PlacementConstraints{AllowedRegions: []string{"nowhere-1"}}, location, nil, "region not allowed"},
+		{"allowed jurisdiction", &common.PlacementConstraints{AllowedJurisdictions: []string{region.Jurisdiction}}, location, nil, ""},
+		{"jurisdiction not allowed", &common.PlacementConstraints{AllowedJurisdictions: []string{"zz"}}, location, nil, "jurisdiction " + region.Jurisdiction + " not allowed"},
+		{"unknown jurisdiction", &common.PlacementConstraints{AllowedJurisdictions: []string{"zz"}}, "nowhere-1", nil, "unknown jurisdiction"},
+		{"missing tag", &common.PlacementConstraints{RequiredTags: []string{"pci"}}, location, nil, "missing required tag pci"},
+		{"tag from config", &common.PlacementConstraints{RequiredTags: []string{"pci"}}, location, map[string][]string{location: {"pci"}}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withConfig(t, func(cfg *Config) {
+				cfg.RegionTags = test.regionTags
+			})
+			if got := ConstraintViolation(test.constraints, test.location); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// Every policy keeps a constrained function inside its allowed regions
+func TestPoliciesRespectConstraints(t *testing.T) {
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, function := range functions {
+		function.Constraints = &common.PlacementConstraints{AllowedRegions: []string{function.Datacenter}}
+		for _, name := range PolicyNames() {
+			policy, _ := GetPolicy(name)
+			for seed := int64(1); seed <= 5; seed++ {
+				opts := NewPolicyOptions(INVERSE_N, seed)
+				opts.State = NewPolicyState()
+				decision, err := policy(function, locations, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decision.OptLocation != function.Datacenter {
+					t.Errorf("%s placed %s at %s outside its allowed region %s", name, function.FunctionName, decision.OptLocation, function.Datacenter)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"radsched/common"
+	"sort"
+	"strings"
+	"time"
+)
+
+const ClientProfilesFile = "client_profiles.json"
+
+// RTTs measured from one client location, and how callers are matched to it
+type ClientProfile struct {
+	ID         string             `json:"id"`
+	Region     string             `json:"region,omitempty"`   // region the client is in or nearest to
+	Prefixes   []string           `json:"prefixes,omitempty"` // CIDR prefixes of callers at this location
+	Location   *common.GeoPoint   `json:"location,omitempty"` // where the client is; defaults to its region's coordinates
+	RTTs       map[string]float64 `json:"rtts"`               // location -> ms, negative if unreachable
+	MeasuredAt time.Time          `json:"measured_at"`
+}
+
+// Identifies the caller of a scheduling decision. RTTs measured by the caller
+// itself take precedence over every profile.
+type ClientRef struct {
+	ID     string             `json:"id,omitempty"`
+	Region string             `json:"region,omitempty"`
+	IP     string             `json:"ip,omitempty"`
+	RTTs   map[string]float64 `json:"rtts,omitempty"`
+}
+
+// Short name of the client for logs: its ID, else its IP, else its region
+func (client ClientRef) label() string {
+	if client.ID != "" {
+		return client.ID
+	}
+	if client.IP != "" {
+		return client.IP
+	}
+	return client.Region
+}
+
+func LoadClientProfiles() ([]ClientProfile, error) {
+	var profiles []ClientProfile
+	_, err := readDataFile(DataPath(ClientProfilesFile), &profiles)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client profiles: %v", err)
+	}
+	return profiles, nil
+}
+
+func SaveClientProfiles(profiles []ClientProfile) error {
+	return writeDataFile(DataPath(ClientProfilesFile), profiles)
+}
+
+// Adds a profile, replacing any profile with the same ID
+func StoreClientProfile(profile ClientProfile) error {
+	profiles, err := LoadClientProfiles()
+	if err != nil {
+		return err
+	}
+	profile.ID = strings.ToLower(profile.ID)
+	profile.Region = strings.ToLower(profile.Region)
+	for _, prefix := range profile.Prefixes {
+		if _, _, err := net.ParseCIDR(prefix); err != nil {
+			return fmt.Errorf("invalid client prefix %s: %v", prefix, err)
+		}
+	}
+
+	replaced := false
+	for i := range profiles {
+		if profiles[i].ID == profile.ID {
+			profiles[i] = profile
+			replaced = true
+		}
+	}
+	if !replaced {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].ID < profiles[j].ID })
+	return SaveClientProfiles(profiles)
+}
+
+// Picks the client RTTs for a caller: its own measurements, then the profile
+// with its ID, the profile with the longest prefix containing its IP, the first
+// profile in its region, the nearest profile to where geoip places its IP or
+// RTTs estimated from that location, and finally the RTTs measured by
+// bootstrap. Returns the client with those RTTs and the region of the matched
+// profile, if any, and a description of the source. geoip may be nil.
+func ResolveClient(client ClientRef, profiles []ClientProfile, geoip *GeoIPDatabase) (ClientRef, string, error) {
+	resolved := client
+	resolved.Region = strings.ToLower(client.Region)
+	fromProfile := func(profile ClientProfile, source string) (ClientRef, string, error) {
+		resolved.RTTs = profile.RTTs
+		if profile.Region != "" {
+			resolved.Region = profile.Region
+		}
+		return resolved, source, nil
+	}
+
+	if len(client.RTTs) > 0 {
+		rtts := make(map[string]float64)
+		for location, rtt := range client.RTTs {
+			rtts[strings.ToLower(location)] = rtt
+		}
+		resolved.RTTs = rtts
+		return resolved, "request", nil
+	}
+
+	if client.ID != "" {
+		for _, profile := range profiles {
+			if profile.ID == strings.ToLower(client.ID) {
+				return fromProfile(profile, "profile "+profile.ID)
+			}
+		}
+	}
+
+	if ip := net.ParseIP(client.IP); ip != nil {
+		var match *ClientProfile
+		longest := -1
+		for i, profile := range profiles {
+			for _, prefix := range profile.Prefixes {
+				_, network, err := net.ParseCIDR(prefix)
+				if err != nil || !network.Contains(ip) {
+					continue
+				}
+				if ones, _ := network.Mask.Size(); ones > longest {
+					longest = ones
+					match = &profiles[i]
+				}
+			}
+		}
+		if match != nil {
+			return fromProfile(*match, "profile "+match.ID+" (prefix)")
+		}
+	}
+
+	if client.Region != "" {
+		for _, profile := range profiles {
+			if profile.Region == strings.ToLower(client.Region) {
+				return fromProfile(profile, "profile "+profile.ID+" (region)")
+			}
+		}
+	}
+
+	if point, located := geoip.Locate(net.ParseIP(client.IP)); located {
+		if profile, distance := nearestProfile(point, profiles); profile != nil && distance <= GetConfig().GeoIP.MaxProfileDistanceKm {
+			return fromProfile(*profile, fmt.Sprintf("profile %s (geoip, %.0f km)", profile.ID, distance))
+		}
+		resolved.RTTs = EstimateClientRTTs(point)
+		return resolved, "geoip distance estimate", nil
+	}
+
+	locations, err := GetLocations()
+	if err != nil {
+		return resolved, "", fmt.Errorf("no client profile matches and failed to load default client RTTs: %v", err)
+	}
+	resolved.RTTs = locations
+	return resolved, "default", nil
+}
+
+// Profile closest to a point and its distance in km; profiles without a
+// location or a known region are skipped
+func nearestProfile(point common.GeoPoint, profiles []ClientProfile) (*ClientProfile, float64) {
+	var nearest *ClientProfile
+	nearestDistance := math.MaxFloat64
+	for i, profile := range profiles {
+		location := profile.Location
+		if location == nil {
+			coordinates, exists := regionLocation(profile.Region)
+			if !exists {
+				continue
+			}
+			location = &coordinates
+		}
+		if distance := GreatCircleKm(point, *location); distance < nearestDistance {
+			nearest = &profiles[i]
+			nearestDistance = distance
+		}
+	}
+	return nearest, nearestDistance
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"radsched/common"
+)
+
+// A caller is matched by its own RTTs, then its ID, the longest prefix, its
+// region, its geoip location and finally the bootstrap RTTs
+func TestResolveClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.csv")
+	if err := os.WriteFile(path, []byte(geoIPFixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	geoip, err := LoadGeoIPDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, func(cfg *Config) { cfg.GeoIP.MaxProfileDistanceKm = 1000 })
+
+	profiles := []ClientProfile{
+		{ID: "london", Region: "eu-west-2", Prefixes: []string{"81.2.0.0/16"}, RTTs: map[string]float64{"eu-west-2": 5}},
+		{ID: "london-office", Region: "eu-west-2", Prefixes: []string{"81.2.69.0/24"}, RTTs: map[string]float64{"eu-west-2": 3}},
+		{ID: "virginia", Region: "us-east-1", RTTs: map[string]float64{"us-east-1": 7}},
+		{ID: "sydney", Region: "ap-southeast-2", Location: &common.GeoPoint{Latitude: -33.87, Longitude: 151.21}, RTTs: map[string]float64{"ap-southeast-2": 4}},
+	}
+	rtts := func(id string) map[string]float64 {
+		for _, profile := range profiles {
+			if profile.ID == id {
+				return profile.RTTs
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name       string
+		client     ClientRef
+		wantSource string // prefix of the reported source
+		wantRegion string
+		wantRTTs   map[string]float64
+	}{
+		{
+			name:       "own RTTs first",
+			client:     ClientRef{ID: "virginia", IP: "81.2.69.1", RTTs: map[string]float64{"EU-West-1": 9}},
+			wantSource: "request",
+			wantRTTs:   map[string]float64{"eu-west-1": 9},
+		},
+		{"ID before prefix", ClientRef{ID: "Virginia", IP: "81.2.69.1"}, "profile virginia", "us-east-1", rtts("virginia")},
+		{"longest prefix", ClientRef{IP: "81.2.69.1"}, "profile london-office (prefix)", "eu-west-2", rtts("london-office")},
+		{"shorter prefix", ClientRef{IP: "81.2.1.1"}, "profile london (prefix)", "eu-west-2", rtts("london")},
+		{"prefix before region", ClientRef{IP: "81.2.1.1", Region: "us-east-1"}, "profile london (prefix)", "eu-west-2", rtts("london")},
+		{"unknown ID falls through", ClientRef{ID: "nobody", Region: "US-East-1"}, "profile virginia (region)", "us-east-1", rtts("virginia")},
+		{"geoip near a profile", ClientRef{IP: "1.0.4.1"}, "profile sydney (geoip", "ap-southeast-2", rtts("sydney")},
+		{"geoip far from every profile", ClientRef{IP: "1.0.1.7"}, "geoip distance estimate", "", nil},
+		{"nothing known", ClientRef{IP: "192.0.2.1"}, "default", "", defaults},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, source, err := ResolveClient(test.client, profiles, geoip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(source, test.wantSource) {
+				t.Errorf("source %q, want %q", source, test.wantSource)
+			}
+			if test.wantRegion != "" && client.Region != test.wantRegion {
+				t.Errorf("region %q, want %q", client.Region, test.wantRegion)
+			}
+			if test.wantRTTs != nil && !reflect.DeepEqual(client.RTTs, test.wantRTTs) {
+				t.Errorf("RTTs %v, want %v", client.RTTs, test.wantRTTs)
+			}
+			if len(client.RTTs) == 0 {
+				t.Error("resolved without RTTs")
+			}
+		})
+	}
+
+	// without a geoip database a located caller gets the bootstrap RTTs
+	if _, source, err := ResolveClient(ClientRef{IP: "1.0.4.1"}, profiles, nil); err != nil || source != "default" {
+		t.Errorf("without geoip: source %q, error %v; want default", source, err)
+	}
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"radsched/common"
+	"strings"
+	"time"
+)
+
+const ColdStartFile = "cold_starts.json"
+
+// Learned extra latency of a cold start for one runtime in one region
+type ColdStartPenalty struct {
+	PenaltyMs float64 `json:"penalty_ms"`
+	Samples   int     `json:"samples"`
+}
+
+// "runtime/region" -> penalty
+type ColdStartPenalties map[string]ColdStartPenalty
+
+func coldStartKey(runtime string, region string) string {
+	if runtime == "" {
+		runtime = "default"
+	}
+	return strings.ToLower(runtime) + "/" + strings.ToLower(region)
+}
+
+func LoadColdStartPenalties() (ColdStartPenalties, error) {
+	penalties := make(ColdStartPenalties)
+	_, err := readDataFile(DataPath(ColdStartFile), &penalties)
+	if os.IsNotExist(err) {
+		return make(ColdStartPenalties), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cold start penalties: %v", err)
+	}
+	return penalties, nil
+}
+
+func SaveColdStartPenalties(penalties ColdStartPenalties) error {
+	if err := writeDataFile(DataPath(ColdStartFile), penalties); err != nil {
+		return fmt.Errorf("failed to write cold start penalties: %v", err)
+	}
+	return nil
+}
+
+// Learned penalty for the runtime and region, or the configured default
+func (penalties ColdStartPenalties) Penalty(runtime string, region string) float64 {
+	if penalty, exists := penalties[coldStartKey(runtime, region)]; exists && penalty.Samples > 0 {
+		return penalty.PenaltyMs
+	}
+	return GetConfig().ColdStart.DefaultPenaltyMs
+}
+
+// Folds one observed cold start penalty into the running average
+func (penalties ColdStartPenalties) learn(runtime string, region string, penaltyMs float64) {
+	key := coldStartKey(runtime, region)
+	penalty := penalties[key]
+	if penalty.Samples == 0 {
+		penalty.PenaltyMs = penaltyMs
+	} else {
+		rate := GetConfig().ColdStart.LearningRate
+		penalty.PenaltyMs += rate * (penaltyMs - penalty.PenaltyMs)
+	}
+	penalty.Samples++
+	penalties[key] = penalty
+}
+
+// Probability an instance is still warm after idling since lastInvoked: certain
+// within the minimum keep-alive window, falling linearly to zero at the maximum
+func WarmProbability(lastInvoked time.Time, now time.Time) float64 {
+	if lastInvoked.IsZero() {
+		return 0
+	}
+	cfg := GetConfig().ColdStart
+	idle := now.Sub(lastInvoked).Seconds()
+	if idle <= cfg.KeepAliveMinSeconds {
+		return 1
+	}
+	if idle >= cfg.KeepAliveMaxSeconds {
+		return 0
+	}
+	return 1 - (idle-cfg.KeepAliveMinSeconds)/(cfg.KeepAliveMaxSeconds-cfg.KeepAliveMinSeconds)
+}
+
+// Whether an invocation hit a cold instance: as reported by the function if it
+// says so, otherwise inferred from how long the location had been idle
+func isColdStart(result InvocationResult, previous *ExecutionProfile) bool {
+	if result.ColdStart != nil {
+		return *result.ColdStart
+	}
+	if previous == nil {
+		return true
+	}
+	return WarmProbability(previous.LastActive(), result.InvokedAt) < 0.5
+}
+
+// Expected cold start cost of running the function at a location at time now
+func expectedColdStart(function common.FunctionInfo, location string, lastInvoked time.Time, now time.Time, penalties ColdStartPenalties) (float64, float64) {
+	coldProbability := 1 - WarmProbability(lastInvoked, now)
+	return coldProbability, penalties.Penalty(function.Runtime, location)
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"radsched/common"
+)
+
+func TestWarmProbability(t *testing.T) {
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	withConfig(t, func(cfg *Config) {
+		cfg.ColdStart.KeepAliveMinSeconds, cfg.ColdStart.KeepAliveMaxSeconds = 300, 900
+	})
+	tests := []struct {
+		name string
+		idle time.Duration
+		want float64
+	}{
+		{"just invoked", 0, 1},
+		{"within the minimum", 5 * time.Minute, 1},
+		{"halfway", 10 * time.Minute, 0.5},
+		{"at the maximum", 15 * time.Minute, 0},
+		{"long idle", 24 * time.Hour, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := WarmProbability(now.Add(-test.idle), now); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+	if got := WarmProbability(time.Time{}, now); got != 0 {
+		t.Errorf("never invoked: got %v, want 0", got)
+	}
+}
+
+func TestExpectedColdStart(t *testing.T) {
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	withConfig(t, func(cfg *Config) {
+		cfg.ColdStart.KeepAliveMinSeconds, cfg.ColdStart.KeepAliveMaxSeconds = 300, 900
+		cfg.ColdStart.DefaultPenaltyMs = 250
+	})
+	penalties := ColdStartPenalties{
+		"python3.12/eu-west-1": {PenaltyMs: 400, Samples: 3},
+		"default/eu-west-1":    {PenaltyMs: 100, Samples: 0},
+	}
+	tests := []struct {
+		name            string
+		runtime         string
+		idle            time.Duration
+		wantProbability float64
+		wantPenalty     float64
+	}{
+		{"learned penalty", "python3.12", 12 * time.Minute, 0.7, 400},
+		{"runtime is case insensitive", "Python3.12", time.Minute, 0, 400},
+		{"no samples uses the default", "", 12 * time.Minute, 0.7, 250},
+		{"unknown runtime uses the default", "go1.x", 20 * time.Minute, 1, 250},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			function := common.FunctionInfo{FunctionName: "cold", Runtime: test.runtime}
+			probability, penalty := expectedColdStart(function, "eu-west-1", now.Add(-test.idle), now, penalties)
+			if math.Abs(probability-test.wantProbability) > 1e-9 || penalty != test.wantPenalty {
+				t.Errorf("got %v x %v, want %v x %v", probability, penalty, test.wantProbability, test.wantPenalty)
+			}
+		})
+	}
+}
+
+func TestColdStartPenaltyLearning(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.ColdStart.LearningRate = 0.5
+	})
+	penalties := make(ColdStartPenalties)
+	for _, observed := range []float64{300, 500, 100} {
+		penalties.learn("python3.12", "EU-West-1", observed)
+	}
+	// 300, then halfway to 500, then halfway to 100
+	if got := penalties.Penalty("python3.12", "eu-west-1"); got != 250 {
+		t.Errorf("learned %v, want 250", got)
+	}
+}
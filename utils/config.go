@@ -0,0 +1,377 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultConfigFile     = "radsched_config.json"
+	configEnvVar          = "RADSCHED_CONFIG"
+	consistencyTokenEnv   = "RADSCHED_CONSISTENCY_TOKEN"
+	defaultFunctionStats  = "http://54.219.54.16/cgi-bin/hit_ratio_v2.py"
+	defaultEdgeStats      = "http://54.219.54.16/cgi-bin/hit_ratio.py"
+	defaultStatsTimeoutMs = 10000
+)
+
+// Endpoints and credentials for the consistency storage server
+type ConsistencyConfig struct {
+	FunctionStatsURL string `json:"function_stats_url"`
+	EdgeStatsURL     string `json:"edge_stats_url"`
+	TimeoutMs        int    `json:"timeout_ms"`
+	AuthHeader       string `json:"auth_header"`
+	AuthToken        string `json:"auth_token"`
+}
+
+// Epsilon-greedy exploration schedule and its parameters
+type ExplorationParams struct {
+	Schedule    string  `json:"schedule"`
+	EpsilonInit float64 `json:"epsilon_init"`
+	EpsilonMin  float64 `json:"epsilon_min"` // Minimum exploration rate
+	EpsilonMax  float64 `json:"epsilon_max"` // Maximum exploration
+	Alpha       float64 `json:"alpha"`       // Learning rate for SMOOTH
+	DecayRate   float64 `json:"decay_rate"`  // Per-hour decay for TIME_DECAY
+}
+
+// Per-function overrides; unset fields fall back to the global parameters
+type ExplorationOverride struct {
+	Schedule    *string  `json:"schedule"`
+	EpsilonInit *float64 `json:"epsilon_init"`
+	EpsilonMin  *float64 `json:"epsilon_min"`
+	EpsilonMax  *float64 `json:"epsilon_max"`
+	Alpha       *float64 `json:"alpha"`
+	DecayRate   *float64 `json:"decay_rate"`
+}
+
+type ExplorationConfig struct {
+	ExplorationParams
+	Functions map[string]ExplorationOverride `json:"functions,omitempty"`
+}
+
+// Which locations a policy may choose; a candidate must pass every list that is set
+type CandidateRules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	Tags  []string `json:"tags,omitempty"` // required tags, see Config.RegionTags
+}
+
+// Global rules plus per-function rules, which apply on top of the global ones
+type CandidatesConfig struct {
+	CandidateRules
+	Functions map[string]CandidateRules `json:"functions,omitempty"`
+}
+
+// How learned execution times replace the declared one
+type ProfilingConfig struct {
+	MinSamples int     `json:"min_samples"` // samples needed before the learned time is used
+	MaxSamples int     `json:"max_samples"` // samples kept per function and location
+	Quantile   float64 `json:"quantile"`    // quantile of the samples to plan with
+}
+
+// Keep-alive window of idle instances and the cold start penalty used until one is learned
+type ColdStartConfig struct {
+	KeepAliveMinSeconds float64 `json:"keep_alive_min_seconds"` // idle time instances always survive
+	KeepAliveMaxSeconds float64 `json:"keep_alive_max_seconds"` // idle time no instance survives
+	DefaultPenaltyMs    float64 `json:"default_penalty_ms"`
+	LearningRate        float64 `json:"learning_rate"` // weight of each new cold start observation
+}
+
+// Which locations the warmer keeps warm and how many warm-ups it may send
+type WarmerConfig struct {
+	TopK            int     `json:"top_k"`             // locations kept warm per function
+	BudgetPerHour   int     `json:"budget_per_hour"`   // warm-ups allowed per hour across all functions
+	MinRatePerHour  float64 `json:"min_rate_per_hour"` // forecast traffic below which a function is not warmed
+	LookbackHours   float64 `json:"lookback_hours"`    // placement history used for the forecast
+	IntervalSeconds int     `json:"interval_seconds"`
+	MaxPlacements   int     `json:"max_placements"` // placements remembered per function
+}
+
+// Constants of the latency model
+type LatencyModelConfig struct {
+	ValidationOverheadMs float64 `json:"validation_overhead_ms"` // primary-side cost of validating speculative reads
+	DatacenterAccessMs   float64 `json:"datacenter_access_ms"`   // cost of one state access inside the primary datacenter
+	Unreachable          string  `json:"unreachable"`            // "exclude" or "penalize" candidates with unreachable or unknown links
+	UnreachablePenaltyMs float64 `json:"unreachable_penalty_ms"` // added to the estimate when penalizing
+}
+
+// Client -> location throughput used for payload transfer time
+type BandwidthConfig struct {
+	DefaultMbps    float64            `json:"default_mbps"`
+	Links          map[string]float64 `json:"links,omitempty"` // per-location overrides of the measurement
+	ProbeURL       string             `json:"probe_url"`       // object downloaded to measure throughput, {region} is substituted
+	ProbeTimeoutMs int                `json:"probe_timeout_ms"`
+}
+
+// Locating callers by IP and estimating RTTs from distance
+type GeoIPConfig struct {
+	Database             string  `json:"database"`                // MaxMind city blocks CSV, relative to data_dir
+	MaxProfileDistanceKm float64 `json:"max_profile_distance_km"` // furthest client profile a located caller may use
+	BaseRTTMs            float64 `json:"base_rtt_ms"`             // RTT at zero distance
+	RTTPerKmMs           float64 `json:"rtt_per_km_ms"`           // RTT added per km of great-circle distance
+}
+
+// How the aws provider measures client RTTs
+type AWSConfig struct {
+	Python     string `json:"python"`      // interpreter, looked up on PATH unless it is a path
+	PingScript string `json:"ping_script"` // relative paths are resolved from the working directory
+}
+
+// Settings of the schedule server
+type ServeConfig struct {
+	TrustedProxies []string `json:"trusted_proxies,omitempty"` // IPs or CIDRs whose X-Forwarded-For is believed
+}
+
+// Size and number of files of the decision audit log
+type DecisionLogConfig struct {
+	Disabled  bool `json:"disabled"`
+	MaxSizeKB int  `json:"max_size_kb"` // size at which decisions.jsonl is rotated
+	MaxFiles  int  `json:"max_files"`   // rotated files kept
+}
+
+type Config struct {
+	DataDir      string              `json:"data_dir"`
+	Consistency  ConsistencyConfig   `json:"consistency"`
+	Exploration  ExplorationConfig   `json:"exploration"`
+	Candidates   CandidatesConfig    `json:"candidates"`
+	RegionTags   map[string][]string `json:"region_tags,omitempty"`
+	Profiling    ProfilingConfig     `json:"profiling"`
+	ColdStart    ColdStartConfig     `json:"cold_start"`
+	Warmer       WarmerConfig        `json:"warmer"`
+	LatencyModel LatencyModelConfig  `json:"latency_model"`
+	Bandwidth    BandwidthConfig     `json:"bandwidth"`
+	GeoIP        GeoIPConfig         `json:"geoip"`
+	DecisionLog  DecisionLogConfig   `json:"decision_log"`
+	Serve        ServeConfig         `json:"serve"`
+	AWS          AWSConfig           `json:"aws"`
+}
+
+var (
+	radschedConfig Config
+	configOnce     sync.Once
+)
+
+func defaultConfig() Config {
+	return Config{
+		DataDir: ".",
+		Consistency: ConsistencyConfig{
+			FunctionStatsURL: defaultFunctionStats,
+			EdgeStatsURL:     defaultEdgeStats,
+			TimeoutMs:        defaultStatsTimeoutMs,
+			AuthHeader:       "Authorization",
+		},
+		Exploration: ExplorationConfig{
+			ExplorationParams: ExplorationParams{
+				Schedule:    "SMOOTH",
+				EpsilonInit: 0.5,
+				EpsilonMin:  0.1,
+				EpsilonMax:  0.9,
+				Alpha:       0.3,
+				DecayRate:   0.1,
+			},
+		},
+		Profiling: ProfilingConfig{
+			MinSamples: 10,
+			MaxSamples: 200,
+			Quantile:   0.5,
+		},
+		ColdStart: ColdStartConfig{
+			KeepAliveMinSeconds: 300,
+			KeepAliveMaxSeconds: 900,
+			DefaultPenaltyMs:    250,
+			LearningRate:        0.2,
+		},
+		Warmer: WarmerConfig{
+			TopK:            2,
+			BudgetPerHour:   60,
+			MinRatePerHour:  1,
+			LookbackHours:   24,
+			IntervalSeconds: 240,
+			MaxPlacements:   500,
+		},
+		LatencyModel: LatencyModelConfig{
+			ValidationOverheadMs: 0,
+			DatacenterAccessMs:   0.5,
+			Unreachable:          "exclude",
+			UnreachablePenaltyMs: 1000,
+		},
+		Bandwidth: BandwidthConfig{
+			DefaultMbps:    100,
+			ProbeTimeoutMs: 30000,
+		},
+		GeoIP: GeoIPConfig{
+			MaxProfileDistanceKm: 500,
+			BaseRTTMs:            5,
+			RTTPerKmMs:           0.015,
+		},
+		DecisionLog: DecisionLogConfig{
+			MaxSizeKB: 10240,
+			MaxFiles:  5,
+		},
+		AWS: AWSConfig{
+			Python:     "python3",
+			PingScript: "ping_edges.py",
+		},
+	}
+}
+
+// Loads the config file named by RADSCHED_CONFIG (or radsched_config.json) on top of the defaults
+func LoadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	path := os.Getenv(configEnvVar)
+	if path == "" {
+		path = defaultConfigFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+	}
+
+	if token := os.Getenv(consistencyTokenEnv); token != "" {
+		cfg.Consistency.AuthToken = token
+	}
+	if cfg.Consistency.TimeoutMs <= 0 {
+		cfg.Consistency.TimeoutMs = defaultStatsTimeoutMs
+	}
+	if cfg.Consistency.AuthHeader == "" {
+		cfg.Consistency.AuthHeader = "Authorization"
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = "."
+	}
+	if cfg.Profiling.Quantile <= 0 || cfg.Profiling.Quantile > 1 {
+		return cfg, fmt.Errorf("profiling.quantile must be in (0, 1], got %v", cfg.Profiling.Quantile)
+	}
+	if cfg.ColdStart.KeepAliveMaxSeconds <= cfg.ColdStart.KeepAliveMinSeconds {
+		return cfg, fmt.Errorf("cold_start.keep_alive_max_seconds must exceed keep_alive_min_seconds")
+	}
+	if cfg.Warmer.LookbackHours <= 0 {
+		return cfg, fmt.Errorf("warmer.lookback_hours must be positive")
+	}
+	if cfg.Warmer.IntervalSeconds <= 0 {
+		return cfg, fmt.Errorf("warmer.interval_seconds must be positive")
+	}
+	if cfg.DecisionLog.MaxSizeKB <= 0 {
+		return cfg, fmt.Errorf("decision_log.max_size_kb must be positive")
+	}
+	cfg.LatencyModel.Unreachable = strings.ToLower(cfg.LatencyModel.Unreachable)
+	if cfg.LatencyModel.Unreachable != "exclude" && cfg.LatencyModel.Unreachable != "penalize" {
+		return cfg, fmt.Errorf("latency_model.unreachable must be \"exclude\" or \"penalize\", got %q", cfg.LatencyModel.Unreachable)
+	}
+	links := make(map[string]float64)
+	for location, mbps := range cfg.Bandwidth.Links {
+		links[strings.ToLower(location)] = mbps
+	}
+	cfg.Bandwidth.Links = links
+	cfg.Exploration.Schedule = strings.ToUpper(cfg.Exploration.Schedule)
+	functions := make(map[string]ExplorationOverride)
+	for name, override := range cfg.Exploration.Functions {
+		functions[strings.ToLower(name)] = override
+	}
+	cfg.Exploration.Functions = functions
+	if err := validateExploration("exploration", cfg.Exploration.ExplorationParams); err != nil {
+		return cfg, err
+	}
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateExploration("exploration.functions."+name, cfg.Exploration.paramsFor(name)); err != nil {
+			return cfg, err
+		}
+	}
+	candidateFunctions := make(map[string]CandidateRules)
+	for name, rules := range cfg.Candidates.Functions {
+		candidateFunctions[strings.ToLower(name)] = rules
+	}
+	cfg.Candidates.Functions = candidateFunctions
+	regionTags := make(map[string][]string)
+	for region, tags := range cfg.RegionTags {
+		regionTags[strings.ToLower(region)] = tags
+	}
+	cfg.RegionTags = regionTags
+
+	return cfg, nil
+}
+
+// Checks exploration parameters, global or with a function's overrides
+// applied, so a bad schedule or bound fails at load rather than per request
+func validateExploration(section string, params ExplorationParams) error {
+	if !IsSchedule(strings.ToUpper(params.Schedule)) {
+		return fmt.Errorf("%s.schedule must be one of %s, got %q", section, strings.Join(Schedules, ", "), params.Schedule)
+	}
+	for _, bound := range []struct {
+		name  string
+		value float64
+	}{{"epsilon_init", params.EpsilonInit}, {"epsilon_min", params.EpsilonMin}, {"epsilon_max", params.EpsilonMax}} {
+		if bound.value < 0 || bound.value > 1 {
+			return fmt.Errorf("%s.%s must be in [0, 1], got %v", section, bound.name, bound.value)
+		}
+	}
+	if params.EpsilonMin > params.EpsilonMax {
+		return fmt.Errorf("%s.epsilon_min %v exceeds epsilon_max %v", section, params.EpsilonMin, params.EpsilonMax)
+	}
+	return nil
+}
+
+// Returns the process-wide config, loading it on first use
+func GetConfig() Config {
+	configOnce.Do(func() {
+		cfg, err := LoadConfig()
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		radschedConfig = cfg
+	})
+	return radschedConfig
+}
+
+// Resolves a data file name against the configured data directory
+func DataPath(name string) string {
+	return filepath.Join(GetConfig().DataDir, name)
+}
+
+// Returns the exploration parameters for a function, applying its overrides
+func ExplorationParamsFor(function string) ExplorationParams {
+	return GetConfig().Exploration.paramsFor(function)
+}
+
+func (exploration ExplorationConfig) paramsFor(function string) ExplorationParams {
+	params := exploration.ExplorationParams
+	override, exists := exploration.Functions[strings.ToLower(function)]
+	if !exists {
+		return params
+	}
+	if override.Schedule != nil {
+		params.Schedule = strings.ToUpper(*override.Schedule)
+	}
+	if override.EpsilonInit != nil {
+		params.EpsilonInit = *override.EpsilonInit
+	}
+	if override.EpsilonMin != nil {
+		params.EpsilonMin = *override.EpsilonMin
+	}
+	if override.EpsilonMax != nil {
+		params.EpsilonMax = *override.EpsilonMax
+	}
+	if override.Alpha != nil {
+		params.Alpha = *override.Alpha
+	}
+	if override.DecayRate != nil {
+		params.DecayRate = *override.DecayRate
+	}
+	return params
+}
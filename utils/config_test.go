@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Exploration settings are checked at load, globally and with each function's overrides
+func TestLoadConfigExploration(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string // substring of the error, empty if the config loads
+	}{
+		{"defaults", `{}`, ""},
+		{"lowercase schedule", `{"exploration": {"schedule": "inverse_n"}}`, ""},
+		{"valid override", `{"exploration": {"functions": {"F1": {"schedule": "fixed", "epsilon_min": 0.05}}}}`, ""},
+		{"misspelled schedule", `{"exploration": {"schedule": "SMOTH"}}`, "exploration.schedule"},
+		{"min above max", `{"exploration": {"epsilon_min": 0.8, "epsilon_max": 0.2}}`, "exploration.epsilon_min 0.8 exceeds"},
+		{"max above 1", `{"exploration": {"epsilon_max": 1.5}}`, "exploration.epsilon_max must be in [0, 1]"},
+		{"negative min", `{"exploration": {"epsilon_min": -0.1}}`, "exploration.epsilon_min must be in [0, 1]"},
+		{"init above 1", `{"exploration": {"epsilon_init": 2}}`, "exploration.epsilon_init"},
+		{"override schedule", `{"exploration": {"functions": {"F1": {"schedule": "NOPE"}}}}`, "exploration.functions.f1.schedule"},
+		{"override min above global max", `{"exploration": {"functions": {"f1": {"epsilon_min": 0.95}}}}`, "exploration.functions.f1.epsilon_min 0.95 exceeds"},
+		{"override out of range", `{"exploration": {"functions": {"f1": {"epsilon_max": -1}}}}`, "exploration.functions.f1.epsilon_max"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), defaultConfigFile)
+			if err := os.WriteFile(path, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(configEnvVar, path)
+			_, err := LoadConfig()
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Errorf("error %v, want one mentioning %q", err, test.wantErr)
+			}
+		})
+	}
+}
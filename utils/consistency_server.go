@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Local stand-in for the consistency storage server. It serves the same
+// hit_ratio (edge -> function -> stats) and hit_ratio_v2 (function -> stats)
+// JSON contracts from files on disk, re-reading them on every request.
+type ConsistencyStatsServer struct {
+	FunctionStatsFile string
+	EdgeStatsFile     string
+	AuthHeader        string
+	AuthToken         string
+}
+
+func (s *ConsistencyStatsServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	functionHandler := s.authorize(s.serveFunctionStats)
+	edgeHandler := s.authorize(s.serveEdgeStats)
+	mux.HandleFunc("/cgi-bin/hit_ratio_v2.py", functionHandler)
+	mux.HandleFunc("/hit_ratio_v2", functionHandler)
+	mux.HandleFunc("/cgi-bin/hit_ratio.py", edgeHandler)
+	mux.HandleFunc("/hit_ratio", edgeHandler)
+	return mux
+}
+
+func (s *ConsistencyStatsServer) serveFunctionStats(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]FunctionStats)
+	if err := readStatsFile(s.FunctionStatsFile, &stats); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *ConsistencyStatsServer) serveEdgeStats(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]map[string]FunctionStats)
+	if err := readStatsFile(s.EdgeStatsFile, &stats); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *ConsistencyStatsServer) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.AuthToken != "" {
+			expected := consistencyAuthValue(ConsistencyConfig{AuthHeader: s.AuthHeader, AuthToken: s.AuthToken})
+			// constant time, so response timing does not reveal how much of the token matched
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(s.AuthHeader)), []byte(expected)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// A missing stats file is served as an empty object, matching a fresh server
+func readStatsFile(path string, out interface{}) error {
+	_, err := readDataFile(path, out)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read stats file: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
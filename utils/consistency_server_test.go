@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The stand-in server serves the fixture's stats under both contracts, and
+// the remote fetchers read them back through the configured URLs and auth
+func TestConsistencyStatsServer(t *testing.T) {
+	wantFunctions, err := FetchHitRatioByFunction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantEdges map[string]map[string]FunctionStats
+	if _, err := readDataFile(DataPath(EdgeConsistencyFile), &wantEdges); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		server     ConsistencyStatsServer
+		client     ConsistencyConfig // auth the fetchers send
+		functions  map[string]FunctionStats
+		edges      map[string]map[string]FunctionStats
+		wantFailed bool
+	}{
+		{
+			name:      "no auth",
+			server:    ConsistencyStatsServer{FunctionStatsFile: DataPath(FunctionConsistencyFile), EdgeStatsFile: DataPath(EdgeConsistencyFile)},
+			functions: wantFunctions,
+			edges:     wantEdges,
+		},
+		{
+			name:      "bearer token",
+			server:    ConsistencyStatsServer{FunctionStatsFile: DataPath(FunctionConsistencyFile), EdgeStatsFile: DataPath(EdgeConsistencyFile), AuthHeader: "Authorization", AuthToken: "secret"},
+			client:    ConsistencyConfig{AuthHeader: "Authorization", AuthToken: "secret"},
+			functions: wantFunctions,
+			edges:     wantEdges,
+		},
+		{
+			name:      "custom header",
+			server:    ConsistencyStatsServer{FunctionStatsFile: DataPath(FunctionConsistencyFile), EdgeStatsFile: DataPath(EdgeConsistencyFile), AuthHeader: "X-Api-Key", AuthToken: "secret"},
+			client:    ConsistencyConfig{AuthHeader: "X-Api-Key", AuthToken: "secret"},
+			functions: wantFunctions,
+			edges:     wantEdges,
+		},
+		{
+			name:       "wrong token",
+			server:     ConsistencyStatsServer{FunctionStatsFile: DataPath(FunctionConsistencyFile), EdgeStatsFile: DataPath(EdgeConsistencyFile), AuthHeader: "Authorization", AuthToken: "secret"},
+			client:     ConsistencyConfig{AuthHeader: "Authorization", AuthToken: "guess"},
+			wantFailed: true,
+		},
+		{
+			name:      "missing files are empty",
+			server:    ConsistencyStatsServer{FunctionStatsFile: filepath.Join(t.TempDir(), "none.json"), EdgeStatsFile: filepath.Join(t.TempDir(), "none.json")},
+			functions: map[string]FunctionStats{},
+			edges:     map[string]map[string]FunctionStats{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(test.server.Handler())
+			defer server.Close()
+			withConfig(t, func(cfg *Config) {
+				cfg.Consistency.FunctionStatsURL = server.URL + "/hit_ratio_v2"
+				cfg.Consistency.EdgeStatsURL = server.URL + "/cgi-bin/hit_ratio.py"
+				cfg.Consistency.AuthHeader, cfg.Consistency.AuthToken = test.client.AuthHeader, test.client.AuthToken
+			})
+
+			functions, err := FetchHitRatioByFunctionRemote()
+			if test.wantFailed {
+				if err == nil {
+					t.Error("fetching function stats succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(functions, test.functions) {
+				t.Errorf("function stats %v, want %v", functions, test.functions)
+			}
+			edges, err := FetchHitRatioByEdgeRemote()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(edges, test.edges) {
+				t.Errorf("edge stats %v, want %v", edges, test.edges)
+			}
+		})
+	}
+
+	server := httptest.NewServer((&ConsistencyStatsServer{}).Handler())
+	defer server.Close()
+	resp, err := http.Post(server.URL+"/hit_ratio", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST answered %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Node name of the client in the coordinate space
+const ClientNode = "client"
+
+const (
+	coordinateRounds    = 200
+	coordinateErrorGain = 0.25 // weight of a new sample in a node's error estimate
+	coordinateStepGain  = 0.25 // fraction of the prediction error a node moves by
+	minCoordinateHeight = 0.1
+)
+
+// Vivaldi coordinate of a node: a point in the plane plus a height for its
+// access link. Error is the node's relative prediction error over Samples
+// measured links.
+type Coordinate struct {
+	X       float64
+	Y       float64
+	Height  float64
+	Error   float64
+	Samples int
+}
+
+type NetworkCoordinates map[string]*Coordinate
+
+// One measured round trip between two nodes
+type rttSample struct {
+	from string
+	to   string
+	rtt  float64
+}
+
+// Fits coordinates to the measured pairs. Samples are replayed in a seeded
+// order so the same measurements always give the same coordinates.
+func FitCoordinates(samples []rttSample) NetworkCoordinates {
+	rng := rand.New(rand.NewSource(1))
+	coordinates := make(NetworkCoordinates)
+	for _, sample := range samples {
+		for _, node := range []string{sample.from, sample.to} {
+			if _, exists := coordinates[node]; !exists {
+				coordinates[node] = &Coordinate{
+					X:      rng.Float64(),
+					Y:      rng.Float64(),
+					Height: minCoordinateHeight,
+					Error:  1,
+				}
+			}
+			coordinates[node].Samples++
+		}
+	}
+
+	order := make([]int, len(samples))
+	for i := range order {
+		order[i] = i
+	}
+	for round := 0; round < coordinateRounds; round++ {
+		rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		for _, i := range order {
+			sample := samples[i]
+			from, to := coordinates[sample.from], coordinates[sample.to]
+			from.observe(to, sample.rtt, rng)
+			to.observe(from, sample.rtt, rng)
+		}
+	}
+	return coordinates
+}
+
+// Adds a node fitted to its measured links while every other node stays
+// where it is, as a node joining a running Vivaldi system would. Samples to
+// nodes without a coordinate are ignored. The receiver is not modified.
+func (coordinates NetworkCoordinates) withNode(node string, samples []rttSample) NetworkCoordinates {
+	rng := rand.New(rand.NewSource(1))
+	joined := make(NetworkCoordinates, len(coordinates)+1)
+	for name, coordinate := range coordinates {
+		joined[name] = coordinate
+	}
+	var known []rttSample
+	for _, sample := range samples {
+		if _, exists := coordinates[sample.to]; exists && sample.to != node {
+			known = append(known, sample)
+		}
+	}
+	if len(known) == 0 {
+		return joined
+	}
+
+	coordinate := &Coordinate{X: rng.Float64(), Y: rng.Float64(), Height: minCoordinateHeight, Error: 1, Samples: len(known)}
+	joined[node] = coordinate
+	for round := 0; round < coordinateRounds; round++ {
+		rng.Shuffle(len(known), func(i, j int) { known[i], known[j] = known[j], known[i] })
+		for _, sample := range known {
+			coordinate.observe(coordinates[sample.to], sample.rtt, rng)
+		}
+	}
+	return joined
+}
+
+// Vivaldi update of c after measuring rtt to other
+func (c *Coordinate) observe(other *Coordinate, rtt float64, rng *rand.Rand) {
+	predicted := c.distance(other)
+	weight := c.Error / (c.Error + other.Error)
+	sampleError := math.Abs(predicted-rtt) / rtt
+	c.Error = sampleError*coordinateErrorGain*weight + c.Error*(1-coordinateErrorGain*weight)
+
+	// move along the unit vector away from other, or a random one if they coincide
+	dx, dy := c.X-other.X, c.Y-other.Y
+	planar := math.Hypot(dx, dy)
+	if planar == 0 {
+		angle := rng.Float64() * 2 * math.Pi
+		dx, dy, planar = math.Cos(angle), math.Sin(angle), 1
+	}
+	norm := planar + c.Height + other.Height
+	step := coordinateStepGain * weight * (rtt - predicted)
+	c.X += step * dx / norm
+	c.Y += step * dy / norm
+	c.Height = math.Max(minCoordinateHeight, c.Height+step*(c.Height+other.Height)/norm)
+}
+
+func (c *Coordinate) distance(other *Coordinate) float64 {
+	return math.Hypot(c.X-other.X, c.Y-other.Y) + c.Height + other.Height
+}
+
+// Predicted RTT between two nodes and its uncertainty in ms. A node fitted to
+// few links can sit anywhere that matches them, so the uncertainty grows as the
+// less measured node's sample count falls. ok is false if either node has no
+// measurements.
+func (coordinates NetworkCoordinates) Estimate(from, to string) (rtt, uncertainty float64, ok bool) {
+	a, aExists := coordinates[from]
+	b, bExists := coordinates[to]
+	if !aExists || !bExists {
+		return 0, 0, false
+	}
+	rtt = a.distance(b)
+	samples := float64(min(a.Samples, b.Samples))
+	return rtt, rtt * ((a.Error+b.Error)/2 + 1/math.Sqrt(samples)), true
+}
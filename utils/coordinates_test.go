@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// Nodes on a plane, where the RTT between two is their distance plus 5 ms of access links
+var planeNodes = map[string][2]float64{
+	"client": {10, 10},
+	"a":      {0, 0},
+	"b":      {60, 0},
+	"c":      {0, 80},
+	"d":      {60, 80},
+	"e":      {30, 40},
+	"f":      {90, 40},
+}
+
+func planeRTT(from, to string) float64 {
+	a, b := planeNodes[from], planeNodes[to]
+	return math.Hypot(a[0]-b[0], a[1]-b[1]) + 5
+}
+
+// Every pair but the held-out ones
+func planeSamples(heldOut map[[2]string]bool) []rttSample {
+	var samples []rttSample
+	nodes := []string{"a", "b", "c", "d", "e", "f"}
+	for _, from := range nodes {
+		for _, to := range nodes {
+			if from < to && !heldOut[[2]string{from, to}] {
+				samples = append(samples, rttSample{from: from, to: to, rtt: planeRTT(from, to)})
+			}
+		}
+	}
+	return samples
+}
+
+func TestFitCoordinates(t *testing.T) {
+	heldOut := map[[2]string]bool{{"a", "d"}: true, {"b", "c"}: true, {"e", "f"}: true}
+	coordinates := FitCoordinates(planeSamples(heldOut))
+
+	tests := []struct {
+		from, to  string
+		tolerance float64 // relative
+	}{
+		{"a", "b", 0.15},
+		{"c", "e", 0.15},
+		{"a", "d", 0.25},
+		{"b", "c", 0.25},
+		{"e", "f", 0.25},
+	}
+	for _, test := range tests {
+		rtt, uncertainty, ok := coordinates.Estimate(test.from, test.to)
+		if !ok {
+			t.Errorf("%s-%s: no estimate", test.from, test.to)
+			continue
+		}
+		want := planeRTT(test.from, test.to)
+		if math.Abs(rtt-want) > test.tolerance*want {
+			t.Errorf("%s-%s: estimate %.1f ms, want %.1f ms within %.0f%%", test.from, test.to, rtt, want, 100*test.tolerance)
+		}
+		if uncertainty <= 0 {
+			t.Errorf("%s-%s: uncertainty %v, want it positive", test.from, test.to, uncertainty)
+		}
+	}
+
+	if _, _, ok := coordinates.Estimate("a", "unknown"); ok {
+		t.Error("estimated a link to a node without measurements")
+	}
+}
+
+func TestFitCoordinatesDeterministic(t *testing.T) {
+	first := FitCoordinates(planeSamples(nil))
+	second := FitCoordinates(planeSamples(nil))
+	for node, coordinate := range first {
+		if *coordinate != *second[node] {
+			t.Errorf("%s fitted to %+v and %+v", node, *coordinate, *second[node])
+		}
+	}
+}
+
+func TestRTTModelLookup(t *testing.T) {
+	locations := map[string]float64{"a": planeRTT("client", "a"), "b": planeRTT("client", "b"), "c": UnreachableRTT}
+	edges := map[string]map[string]float64{
+		"a": {"a": 0.5, "b": planeRTT("a", "b"), "c": planeRTT("a", "c"), "e": planeRTT("a", "e")},
+		"b": {"c": planeRTT("b", "c"), "e": planeRTT("b", "e"), "f": UnreachableRTT},
+		"c": {"e": planeRTT("c", "e")},
+		"d": {"a": planeRTT("d", "a"), "b": planeRTT("d", "b"), "e": planeRTT("d", "e")},
+	}
+	model := newRTTModel(locations, edges)
+
+	tests := []struct {
+		name     string
+		estimate RTTEstimate
+		want     RTTStatus
+		rtt      float64 // expected RTT for measured links, 0 to skip
+	}{
+		{"measured client link", model.clientRTT("a"), RTTMeasured, locations["a"]},
+		{"unreachable client link", model.clientRTT("c"), RTTUnreachable, 0},
+		{"imputed client link", model.clientRTT("e"), RTTImputed, 0},
+		{"unknown client link", model.clientRTT("nowhere"), RTTUnknown, 0},
+		{"measured edge link", model.edgeRTT("a", "b"), RTTMeasured, edges["a"]["b"]},
+		{"measured in reverse", model.edgeRTT("e", "c"), RTTMeasured, edges["c"]["e"]},
+		{"unreachable edge link", model.edgeRTT("b", "f"), RTTUnreachable, 0},
+		{"imputed edge link", model.edgeRTT("c", "d"), RTTImputed, 0},
+		{"unknown edge link", model.edgeRTT("a", "nowhere"), RTTUnknown, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.estimate.Status != test.want {
+				t.Fatalf("status %s, want %s", test.estimate.Status, test.want)
+			}
+			switch test.want {
+			case RTTMeasured:
+				if test.estimate.RTT != test.rtt || test.estimate.Uncertainty != 0 {
+					t.Errorf("got %+v, want %.2f ms with no uncertainty", test.estimate, test.rtt)
+				}
+			case RTTImputed:
+				if test.estimate.RTT <= 0 || test.estimate.Uncertainty <= 0 {
+					t.Errorf("got %+v, want a positive RTT and uncertainty", test.estimate)
+				}
+			case RTTUnknown:
+				if !IsUnreachable(test.estimate.RTT) {
+					t.Errorf("got %+v, want no RTT", test.estimate)
+				}
+			}
+		})
+	}
+
+	// the client and e are both fitted, so the unmeasured link between them is close to the truth
+	if imputed, want := model.clientRTT("e").RTT, planeRTT("client", "e"); math.Abs(imputed-want) > 0.5*want {
+		t.Errorf("imputed client RTT to e is %.1f ms, want about %.1f ms", imputed, want)
+	}
+}
+
+func TestEdgeCoordinatesCache(t *testing.T) {
+	edges := map[string]map[string]float64{
+		"a": {"b": planeRTT("a", "b"), "c": planeRTT("a", "c")},
+		"b": {"c": planeRTT("b", "c")},
+	}
+	first := edgeCoordinates(edges)
+	if second := edgeCoordinates(edges); second["a"] != first["a"] {
+		t.Error("refitted unchanged edge RTTs")
+	}
+
+	edges["b"]["c"]++
+	changed := edgeCoordinates(edges)
+	if changed["a"] == first["a"] {
+		t.Error("kept the fit after the edge RTTs changed")
+	}
+
+	// placing a client leaves the shared fit alone
+	before := *changed["a"]
+	joined := changed.withNode(ClientNode, []rttSample{{from: ClientNode, to: "a", rtt: 20}, {from: ClientNode, to: "b", rtt: 40}})
+	if _, exists := changed[ClientNode]; exists || *changed["a"] != before {
+		t.Error("withNode modified the cached coordinates")
+	}
+	if _, _, ok := joined.Estimate(ClientNode, "c"); !ok {
+		t.Error("no estimate from the placed client to c")
+	}
+}
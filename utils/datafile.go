@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Version written into every data file. Files without a version envelope are
+// treated as version 1 and are still readable; `radsched migrate` upgrades them.
+const DataFileVersion = 2
+
+// On-disk wrapper around a data file's payload
+type dataFileEnvelope struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Reads a data file into out and returns its version. Errors from opening
+// the file are returned unwrapped so callers can check os.IsNotExist.
+func readDataFile(path string, out interface{}) (int, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	version, payload, err := unwrapDataFile(raw)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if version > DataFileVersion {
+		return version, fmt.Errorf("%s has version %d, newer than supported version %d", path, version, DataFileVersion)
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return version, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return version, nil
+}
+
+// Splits a file into its version and payload, accepting unversioned legacy files
+func unwrapDataFile(raw []byte) (int, json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &fields); err != nil {
+			return 0, nil, err
+		}
+		_, hasVersion := fields["version"]
+		_, hasData := fields["data"]
+		if hasVersion && hasData && len(fields) == 2 {
+			var envelope dataFileEnvelope
+			if err := json.Unmarshal(trimmed, &envelope); err == nil {
+				return envelope.Version, envelope.Data, nil
+			}
+		}
+	}
+	return 1, trimmed, nil
+}
+
+// Writes data wrapped in a version envelope
+func writeDataFile(path string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(dataFileEnvelope{Version: DataFileVersion, Data: payload}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// written aside and renamed over the file, so readers never see it half written
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(append(out, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Data files being updated in this process, by path
+var dataFileLocks sync.Map
+
+// Runs update while holding an exclusive lock on a data file, so read-modify-
+// write cycles from concurrent requests and from processes sharing the data
+// directory, such as serve, run --invoke and the warmer, do not lose each
+// other's changes
+func updateDataFile(name string, update func() error) error {
+	path := DataPath(name)
+	mu, _ := dataFileLocks.LoadOrStore(path, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	unlock, err := lockFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %v", name, err)
+	}
+	defer unlock()
+	return update()
+}
@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"radsched/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DecisionLogFile = "decisions.jsonl"
+
+// appends from concurrent schedule requests must not interleave or race a
+// rotation; other processes such as run and serve are kept out by lockFile
+var decisionLogMu sync.Mutex
+
+// A candidate as the policy saw it when deciding
+type DecisionCandidate struct {
+	Location string  `json:"location"`
+	Estimate float64 `json:"estimate_ms"`
+	Weight   float64 `json:"weight"`   // consistency failure weight, weighted policy only
+	Eligible bool    `json:"eligible"` // could have been chosen: not filtered and faster than the datacenter
+	Reason   string  `json:"reason,omitempty"`
+}
+
+// What happened after a decision was carried out
+type DecisionOutcome struct {
+	Latency      float64 `json:"latency_ms"`
+	Inconsistent *bool   `json:"inconsistent,omitempty"` // nil if unknown
+}
+
+// One scheduling decision with the probability the policy had of making it.
+// In the audit log an outcome observed later is appended as a separate line
+// holding only the decision's ID and the outcome, and merged when read.
+type DecisionRecord struct {
+	ID         string              `json:"id,omitempty"`
+	Timestamp  time.Time           `json:"timestamp"`
+	Function   string              `json:"function,omitempty"`
+	Client     string              `json:"client,omitempty"`
+	Policy     string              `json:"policy,omitempty"`
+	Epsilon    float64             `json:"epsilon,omitempty"`
+	Seed       int64               `json:"seed,omitempty"` // seed of the policy's random source, for replay
+	Explored   bool                `json:"explored,omitempty"`
+	Propensity float64             `json:"propensity,omitempty"`
+	Location   string              `json:"location,omitempty"`
+	Estimate   float64             `json:"estimate_ms,omitempty"`
+	Candidates []DecisionCandidate `json:"candidates,omitempty"`
+	Outcome    *DecisionOutcome    `json:"outcome,omitempty"`
+}
+
+func NewDecisionRecord(at time.Time, function string, client string, policy string, info common.ExecutionInfo) DecisionRecord {
+	record := DecisionRecord{
+		Timestamp:  at.UTC(),
+		Function:   function,
+		Client:     client,
+		Policy:     policy,
+		Epsilon:    info.Epsilon,
+		Seed:       info.Seed,
+		Explored:   info.Explored,
+		Propensity: info.Propensity,
+		Location:   info.OptLocation,
+		Estimate:   info.ExecutionTime,
+	}
+	for _, candidate := range info.Candidates {
+		record.Candidates = append(record.Candidates, DecisionCandidate{
+			Location: candidate.Location,
+			Estimate: candidate.Estimate,
+			Weight:   candidate.Weight,
+			Eligible: candidate.Eligible,
+			Reason:   candidate.Reason,
+		})
+	}
+	return record
+}
+
+// Reads a decision log with one JSON record per line, merging outcome lines
+// into their decisions
+func LoadDecisionLog(path string) ([]DecisionRecord, error) {
+	history := &decisionLog{index: make(map[string]int)}
+	if err := history.read(path); err != nil {
+		return nil, err
+	}
+	return history.records, nil
+}
+
+// Reads the audit log in the data directory, rotated files first, oldest to newest
+func LoadDecisionHistory() ([]DecisionRecord, error) {
+	history := &decisionLog{index: make(map[string]int)}
+	path := DataPath(DecisionLogFile)
+	for i := GetConfig().DecisionLog.MaxFiles; i >= 1; i-- {
+		if err := history.read(rotatedDecisionLog(path, i)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if err := history.read(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return history.records, nil
+}
+
+// Looks up a logged decision by its ID in the audit log and its rotated files
+func FindDecision(id string) (DecisionRecord, error) {
+	records, err := LoadDecisionHistory()
+	if err != nil {
+		return DecisionRecord{}, fmt.Errorf("failed to load decision log: %v", err)
+	}
+	for _, record := range records {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return DecisionRecord{}, fmt.Errorf("decision %s is not in the decision log", id)
+}
+
+// Records read so far and the position of each decision by ID
+type decisionLog struct {
+	records []DecisionRecord
+	index   map[string]int
+}
+
+// Errors from opening the file are returned unwrapped so callers can check os.IsNotExist
+func (l *decisionLog) read(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record DecisionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("failed to parse %s line %d: %v", path, line, err)
+		}
+		if position, exists := l.index[record.ID]; exists && record.ID != "" && record.Function == "" {
+			l.records[position].Outcome = record.Outcome
+			continue
+		}
+		if record.Function == "" {
+			// outcome of a decision rotated out of the log
+			continue
+		}
+		if record.ID != "" {
+			l.index[record.ID] = len(l.records)
+		}
+		l.records = append(l.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return nil
+}
+
+// Which decisions a history query returns; empty fields match everything
+type DecisionFilter struct {
+	Function string
+	Client   string
+	Policy   string
+	Location string
+	Since    time.Time
+	Explored bool // only exploration steps
+	Limit    int  // most recent decisions kept, 0 for all
+}
+
+// Decisions matching the filter, oldest first
+func FilterDecisions(records []DecisionRecord, filter DecisionFilter) []DecisionRecord {
+	var matched []DecisionRecord
+	for _, record := range records {
+		if (filter.Function != "" && !strings.EqualFold(record.Function, filter.Function)) ||
+			(filter.Client != "" && !strings.EqualFold(record.Client, filter.Client)) ||
+			(filter.Policy != "" && !strings.EqualFold(record.Policy, filter.Policy)) ||
+			(filter.Location != "" && !strings.EqualFold(record.Location, filter.Location)) ||
+			record.Timestamp.Before(filter.Since) ||
+			(filter.Explored && !record.Explored) {
+			continue
+		}
+		matched = append(matched, record)
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	return matched
+}
+
+// Appends a decision to the audit log in the data directory and returns its ID
+func RecordDecision(record DecisionRecord) (string, error) {
+	if GetConfig().DecisionLog.Disabled {
+		return "", nil
+	}
+	if record.ID == "" {
+		record.ID = newDecisionID()
+	}
+	return record.ID, appendDecisionLog(record)
+}
+
+// Appends the observed outcome of a logged decision
+func RecordDecisionOutcome(id string, outcome DecisionOutcome) error {
+	if GetConfig().DecisionLog.Disabled || id == "" {
+		return nil
+	}
+	return appendDecisionLog(DecisionRecord{ID: id, Timestamp: time.Now().UTC(), Outcome: &outcome})
+}
+
+func appendDecisionLog(record DecisionRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	decisionLogMu.Lock()
+	defer decisionLogMu.Unlock()
+
+	path := DataPath(DecisionLogFile)
+	unlock, err := lockFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to lock decision log: %v", err)
+	}
+	defer unlock()
+	if err := rotateDecisionLog(path, int64(len(line)+1)); err != nil {
+		return fmt.Errorf("failed to rotate decision log: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open decision log: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write decision log: %v", err)
+	}
+	return nil
+}
+
+// Shifts path to path.1, path.1 to path.2 and so on when adding size bytes
+// would take it past the configured size, dropping the oldest file
+func rotateDecisionLog(path string, size int64) error {
+	cfg := GetConfig().DecisionLog
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+size <= int64(cfg.MaxSizeKB)*1024 {
+		return nil
+	}
+	if cfg.MaxFiles < 1 {
+		return os.Remove(path)
+	}
+	if err := os.Remove(rotatedDecisionLog(path, cfg.MaxFiles)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := cfg.MaxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedDecisionLog(path, i), rotatedDecisionLog(path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, rotatedDecisionLog(path, 1))
+}
+
+func rotatedDecisionLog(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+func newDecisionID() string {
+	random := make([]byte, 4)
+	rand.Read(random)
+	return fmt.Sprintf("%x-%s", time.Now().UnixNano(), hex.EncodeToString(random))
+}
+
+// Writes records to path, one JSON record per line
+func WriteDecisionLog(path string, records []DecisionRecord) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"radsched/common"
+)
+
+func TestRotateDecisionLog(t *testing.T) {
+	cfg := GetConfig().DecisionLog
+	limit := int64(cfg.MaxSizeKB) * 1024
+
+	tests := []struct {
+		name     string
+		existing []string // contents of the log, then of .1, .2 and so on; "" for a missing file, "-" for an empty one
+		size     int64
+		want     []string // contents after rotating, in the same order
+	}{
+		{"no log", []string{""}, limit + 1, []string{""}},
+		{"empty log", []string{"-"}, limit + 1, []string{"-"}},
+		{"room left", []string{"current"}, limit - int64(len("current")), []string{"current"}},
+		{"first rotation", []string{"current"}, limit, []string{"", "current"}},
+		{"shifts rotated files", []string{"current", "older", "oldest"}, limit, []string{"", "current", "older", "oldest"}},
+		{"gap in rotated files", []string{"current", "", "oldest"}, limit, []string{"", "current", "", "oldest"}},
+		{"drops the oldest", []string{"0", "1", "2", "3", "4", "5"}, limit, []string{"", "0", "1", "2", "3", "4"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DecisionLogFile)
+			name := func(i int) string {
+				if i == 0 {
+					return path
+				}
+				return rotatedDecisionLog(path, i)
+			}
+			for i, contents := range test.existing {
+				if contents == "-" {
+					contents = ""
+				} else if contents == "" {
+					continue
+				}
+				if err := os.WriteFile(name(i), []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := rotateDecisionLog(path, test.size); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i <= cfg.MaxFiles+1; i++ {
+				want := ""
+				if i < len(test.want) {
+					want = test.want[i]
+				}
+				got, err := os.ReadFile(name(i))
+				switch {
+				case want == "" && err == nil && len(got) > 0:
+					t.Errorf("%s holds %q, want it missing", name(i), got)
+				case want == "-" && (err != nil || len(got) > 0):
+					t.Errorf("%s: %q, %v; want it empty", name(i), got, err)
+				case want != "" && want != "-" && string(got) != want:
+					t.Errorf("%s holds %q (%v), want %q", name(i), got, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecisionLogRoundTrip(t *testing.T) {
+	at := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	info := common.ExecutionInfo{
+		OptLocation:   "eu-west-1",
+		ExecutionTime: 42,
+		Epsilon:       0.2,
+		Seed:          99,
+		Propensity:    0.9,
+		Candidates: []common.CandidateInfo{
+			{Location: "eu-west-1", Estimate: 42, Weight: 0.1, Eligible: true},
+			{Location: "us-east-1", Estimate: 50, Filtered: true, Reason: "residency"},
+		},
+	}
+	record := NewDecisionRecord(at, "function1", "client-1", "weighted", info)
+	record.ID = "decision-1"
+	inconsistent := true
+
+	path := filepath.Join(t.TempDir(), DecisionLogFile)
+	if err := WriteDecisionLog(path, []DecisionRecord{
+		record,
+		{ID: "decision-1", Timestamp: at.Add(time.Second), Outcome: &DecisionOutcome{Latency: 55, Inconsistent: &inconsistent}},
+		{ID: "rotated-out", Timestamp: at, Outcome: &DecisionOutcome{Latency: 1}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadDecisionLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("read %d records, want 1", len(records))
+	}
+	got := records[0]
+	if got.Seed != 99 || got.Propensity != 0.9 || got.Location != "eu-west-1" || !got.Timestamp.Equal(at) {
+		t.Errorf("read %+v", got)
+	}
+	if got.Outcome == nil || got.Outcome.Latency != 55 || !*got.Outcome.Inconsistent {
+		t.Errorf("outcome %+v was not merged", got.Outcome)
+	}
+	if len(got.Candidates) != 2 || !got.Candidates[0].Eligible || got.Candidates[1].Eligible {
+		t.Errorf("candidates %+v", got.Candidates)
+	}
+}
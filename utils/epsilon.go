@@ -3,127 +3,246 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"math"
 	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
-	epsilonfile = "epsilon.json"
 	ADAPTIVE = "ADAPTIVE" 
 	SMOOTH = "SMOOTH" 
+	TIME_DECAY = "TIME_DECAY"
+	INVERSE_N = "INVERSE_N"
+	FIXED = "FIXED"
 )
 
-var (
-	epsilonInit = 0.5
-	epsilonMin = 0.1   // Minimum exploration rate
-	epsilonMax = 0.9   // Maximum exploration
-	alpha 	   = 0.3   // Learning rate
-)
+// Schedules that adjust epsilon from the function's consistency hit ratio, in
+// the client region when it is given
+var hitRatioSchedules = map[string]bool{ADAPTIVE: true, SMOOTH: true}
+
+var Schedules = []string{ADAPTIVE, SMOOTH, TIME_DECAY, INVERSE_N, FIXED}
+
+// Exploration state for a function, or for a function as seen from one client region
+type ExplorationState struct {
+	Epsilon     float64   `json:"epsilon"`
+	Updates     int       `json:"updates"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+type FunctionExploration struct {
+	ExplorationState
+	Regions map[string]ExplorationState `json:"regions,omitempty"`
+}
 
-// Get and update epsilon for a given function and adjustment method
-func GetEpsilon(function string, adjustMethod string) (float64, error) {	
+// Get and update epsilon for a given function, client region (optional) and schedule.
+// An empty schedule uses the one configured for the function.
+func GetEpsilon(function string, region string, schedule string) (float64, error) {
 	epsilonData, err := LoadEpsilon()
 	if (err != nil) {
 		return 0.0, err
 	}
-	epsilon0, exists := epsilonData[strings.ToLower(function)] 
-	if (!exists || len(epsilonData) == 0) {
-		epsilonData[strings.ToLower(function)] = epsilonInit
-		SaveEpsilon(epsilonData)
+	epsilon, err := UpdateEpsilon(epsilonData, function, region, schedule, time.Now())
+	if err != nil {
+		return 0.0, err
+	}
+	if err := SaveEpsilon(epsilonData); err != nil {
+		return 0.0, err
 	}
+	return epsilon, nil
+}
 
-	// get function hit ratio data
-	hitRatios, err := FetchHitRatioByFunction() 
-	if err != nil {
-		log.Fatalf("Error fetching hit ratio data %v", err)
+// Same as GetEpsilon, updating the given exploration state at time now instead of epsilon.json
+func UpdateEpsilon(epsilonData map[string]FunctionExploration, function string, region string, schedule string, now time.Time) (float64, error) {
+	function = strings.ToLower(function)
+	region = strings.ToLower(region)
+
+	params := ExplorationParamsFor(function)
+	if schedule != "" {
+		params.Schedule = strings.ToUpper(schedule)
+	}
+	if !IsSchedule(params.Schedule) {
+		return 0.0, fmt.Errorf("invalid epsilon adjustment method: %s", params.Schedule)
+	}
+
+	functionState, exists := epsilonData[function]
+	if (!exists) {
+		functionState = FunctionExploration{ExplorationState: ExplorationState{Epsilon: params.EpsilonInit}}
 	}
-	functionHitRatio, exists := hitRatios[strings.ToLower(function)]
-	if !exists {
-		return epsilon0, nil
+	state := functionState.ExplorationState
+	if region != "" {
+		regionState, exists := functionState.Regions[region]
+		if !exists {
+			regionState = ExplorationState{Epsilon: state.Epsilon}
+		}
+		state = regionState
 	}
-	successRate := float64(functionHitRatio.NumSuccess) / float64(functionHitRatio.NumAttempts)
 
-	// adjust epsilon proportionally to ratio and num attempts
+	// adjust epsilon according to the schedule
 	var epsilonNew float64
-	switch adjustMethod {
-	case ADAPTIVE:
-		epsilonNew = EpsilonAdjustAdaptive(successRate)
-	case SMOOTH:
-		epsilonNew = EpsilonAdjustAdaptiveSmooth(epsilon0, successRate)
-	default:
-		return 0.0, fmt.Errorf("invalid epsilon adjustment method: %s", adjustMethod)
-	}	
-	
-	epsilonData[function] = epsilonNew
-	SaveEpsilon(epsilonData)
+	switch params.Schedule {
+	case TIME_DECAY:
+		epsilonNew = EpsilonDecayTime(params, state, now)
+	case INVERSE_N:
+		epsilonNew = EpsilonDecayInverse(params, state.Updates)
+	case FIXED:
+		epsilonNew = clampEpsilon(params, params.EpsilonInit)
+	}
+
+	if hitRatioSchedules[params.Schedule] {
+		hitRatio, err := hitRatioFor(function, region)
+		if err != nil {
+			return 0, err
+		}
+		if hitRatio.NumAttempts == 0 {
+			return state.Epsilon, nil
+		}
+		successRate := float64(hitRatio.NumSuccess) / float64(hitRatio.NumAttempts)
+
+		// adjust epsilon proportionally to ratio and num attempts
+		if params.Schedule == ADAPTIVE {
+			epsilonNew = EpsilonAdjustAdaptive(params, successRate)
+		} else {
+			epsilonNew = EpsilonAdjustAdaptiveSmooth(params, state.Epsilon, successRate)
+		}
+	}
+
+	state.Epsilon = epsilonNew
+	state.Updates++
+	state.LastUpdated = now.UTC()
+	if region != "" {
+		if functionState.Regions == nil {
+			functionState.Regions = make(map[string]ExplorationState)
+		}
+		functionState.Regions[region] = state
+	} else {
+		functionState.ExplorationState = state
+	}
+	epsilonData[function] = functionState
 
 	return epsilonNew, nil
 }
 
-// Get new epsilon inversely proportional to success rate
-func EpsilonAdjustAdaptive(successRate float64) float64 {
-	epsilonNew := 1 - successRate
-
-	if epsilonNew > epsilonMax {
-		epsilonNew = epsilonMax
+// Consistency statistics driving the hit ratio schedules: those of the
+// function at the edge in the client's region, or the function-wide ones when
+// there is no region or the edge has no attempts for the function
+func hitRatioFor(function string, region string) (FunctionStats, error) {
+	if region != "" {
+		var edgeStats map[string]map[string]FunctionStats
+		_, err := readDataFile(DataPath(EdgeConsistencyFile), &edgeStats)
+		if err != nil && !os.IsNotExist(err) {
+			return FunctionStats{}, fmt.Errorf("failed to read edge-function consistency cache: %v", err)
+		}
+		if stats := edgeStats[region][function]; stats.NumAttempts > 0 {
+			return stats, nil
+		}
 	}
-	if epsilonNew < epsilonMin {
-		return epsilonMin
+	hitRatios, err := FetchHitRatioByFunction()
+	if err != nil {
+		return FunctionStats{}, fmt.Errorf("failed to fetch hit ratio data: %v", err)
+	}
+	return hitRatios[function], nil
+}
+
+func IsSchedule(schedule string) bool {
+	for _, known := range Schedules {
+		if schedule == known {
+			return true
+		}
 	}
-	
-	return epsilonNew
+	return false
+}
+
+// Get new epsilon inversely proportional to success rate
+func EpsilonAdjustAdaptive(params ExplorationParams, successRate float64) float64 {
+	return clampEpsilon(params, 1 - successRate)
 }
 
 // Update epsilon inversely proportional to success rate with a smoothing factor
-func EpsilonAdjustAdaptiveSmooth(epsilon0 float64, successRate float64) float64 {
+func EpsilonAdjustAdaptiveSmooth(params ExplorationParams, epsilon0 float64, successRate float64) float64 {
 	targetEpsilon := 1 - successRate
-	epsilonNew := epsilon0 + alpha *(targetEpsilon - epsilon0)
+	return clampEpsilon(params, epsilon0 + params.Alpha *(targetEpsilon - epsilon0))
+}
 
-	if epsilonNew > epsilonMax {
-		epsilonNew = epsilonMax
-	}
-	if epsilonNew < epsilonMin {
-		return epsilonMin
+// Decay epsilon exponentially with the hours elapsed since the last update
+func EpsilonDecayTime(params ExplorationParams, state ExplorationState, now time.Time) float64 {
+	if state.LastUpdated.IsZero() {
+		return clampEpsilon(params, state.Epsilon)
 	}
-	
-	return epsilonNew
+	hours := now.Sub(state.LastUpdated).Hours()
+	return clampEpsilon(params, state.Epsilon * math.Exp(-params.DecayRate * hours))
+}
+
+// Decay epsilon as epsilonInit / n over the number of updates
+func EpsilonDecayInverse(params ExplorationParams, updates int) float64 {
+	return clampEpsilon(params, params.EpsilonInit / float64(updates + 1))
 }
 
-// Fetches epsilon data 
-func LoadEpsilon() (map[string]float64, error) {
-	// If file doesn't exist, return an empty map
-	if _, err := os.Stat(epsilonfile); os.IsNotExist(err) {
-		return make(map[string]float64), nil
+func clampEpsilon(params ExplorationParams, epsilon float64) float64 {
+	if epsilon > params.EpsilonMax {
+		epsilon = params.EpsilonMax
 	}
+	if epsilon < params.EpsilonMin {
+		return params.EpsilonMin
+	}
+	return epsilon
+}
 
-	// Read file contents
-	data, err := os.ReadFile(epsilonfile)
+// Fetches exploration state, migrating the legacy function -> epsilon format
+func LoadEpsilon() (map[string]FunctionExploration, error) {
+	// Read file contents; if file doesn't exist, return an empty map
+	rawData := make(map[string]json.RawMessage)
+	_, err := readDataFile(DataPath(EpsilonFile), &rawData)
+	if os.IsNotExist(err) {
+		return make(map[string]FunctionExploration), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read epsilon file: %v", err)
 	}
 
-	// Place contents in map
-	epsilonData := make(map[string]float64)
-	err = json.Unmarshal(data, &epsilonData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse epsilon JSON: %v", err)
-	}
+	return decodeEpsilon(rawData)
+}
 
+// Decodes exploration state, migrating legacy function -> epsilon entries
+func decodeEpsilon(rawData map[string]json.RawMessage) (map[string]FunctionExploration, error) {
+	epsilonData := make(map[string]FunctionExploration)
+	for _, key := range sortedLegacyKeys(rawData) {
+		var state FunctionExploration
+		var legacyEpsilon float64
+		if err := json.Unmarshal(rawData[key], &legacyEpsilon); err == nil {
+			state.Epsilon = legacyEpsilon
+		} else if err := json.Unmarshal(rawData[key], &state); err != nil {
+			return nil, fmt.Errorf("failed to parse epsilon state for %s: %v", key, err)
+		}
+		epsilonData[strings.ToLower(key)] = state
+	}
 	return epsilonData, nil
 }
 
-// Writes epsilon values to file 
-func SaveEpsilon(epsilonData map[string]float64) error {
-	data, err := json.MarshalIndent(epsilonData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal epsilon JSON: %v", err)
+// Legacy files stored the initial epsilon under the lowercased name and later
+// updates under the name as given, so mixed-case keys are applied last to win
+func sortedLegacyKeys(rawData map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(rawData))
+	for key := range rawData {
+		keys = append(keys, key)
 	}
+	sort.Slice(keys, func(i, j int) bool {
+		iLower := keys[i] == strings.ToLower(keys[i])
+		jLower := keys[j] == strings.ToLower(keys[j])
+		if iLower != jLower {
+			return iLower
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
 
-	err = os.WriteFile(epsilonfile, data, 0644)
-	if err != nil {
+// Writes exploration state to file 
+func SaveEpsilon(epsilonData map[string]FunctionExploration) error {
+	if err := writeDataFile(DataPath(EpsilonFile), epsilonData); err != nil {
 		return fmt.Errorf("failed to write epsilon file: %v", err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
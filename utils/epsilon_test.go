@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUpdateEpsilonRegions(t *testing.T) {
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	// function1 succeeds 80% of the time overall, 50% at eu-west-1 and 90% at
+	// eu-west-2; us-east-1 has no attempts and falls back to the overall ratio
+	withDataCopy(t)
+	functionStats := map[string]FunctionStats{"function1": {NumAttempts: 10, NumSuccess: 8, NumFailure: 2}}
+	edgeStats := map[string]map[string]FunctionStats{
+		"eu-west-1": {"function1": {NumAttempts: 10, NumSuccess: 5, NumFailure: 5}},
+		"eu-west-2": {"function1": {NumAttempts: 10, NumSuccess: 9, NumFailure: 1}},
+		"us-east-1": {"function1": {}},
+	}
+	if err := writeDataFile(DataPath(FunctionConsistencyFile), functionStats); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDataFile(DataPath(EdgeConsistencyFile), edgeStats); err != nil {
+		t.Fatal(err)
+	}
+	type update struct {
+		region string
+		want   float64
+	}
+	tests := []struct {
+		name     string
+		schedule string
+		updates  []update
+		// expected updates of the function-wide state and of each region
+		wantUpdates map[string]int
+	}{
+		{
+			name:        "function only",
+			schedule:    INVERSE_N,
+			updates:     []update{{"", 0.5}, {"", 0.25}, {"", 0.5 / 3}},
+			wantUpdates: map[string]int{"": 3},
+		},
+		{
+			name:        "regions are independent",
+			schedule:    INVERSE_N,
+			updates:     []update{{"eu-west-1", 0.5}, {"eu-west-1", 0.25}, {"us-east-1", 0.5}},
+			wantUpdates: map[string]int{"": 0, "eu-west-1": 2, "us-east-1": 1},
+		},
+		{
+			name:        "region keys are case insensitive",
+			schedule:    INVERSE_N,
+			updates:     []update{{"EU-West-1", 0.5}, {"eu-west-1", 0.25}},
+			wantUpdates: map[string]int{"eu-west-1": 2},
+		},
+		{
+			name:        "region next to the function",
+			schedule:    INVERSE_N,
+			updates:     []update{{"", 0.5}, {"ap-south-1", 0.5}, {"", 0.25}},
+			wantUpdates: map[string]int{"": 2, "ap-south-1": 1},
+		},
+		{
+			name:        "adaptive follows the region's hit ratio",
+			schedule:    ADAPTIVE,
+			updates:     []update{{"eu-west-1", 0.5}, {"us-east-1", 0.2}, {"", 0.2}},
+			wantUpdates: map[string]int{"": 1, "eu-west-1": 1, "us-east-1": 1},
+		},
+		{
+			name:        "smooth moves each region toward its own hit ratio",
+			schedule:    SMOOTH,
+			updates:     []update{{"eu-west-2", 0.38}, {"eu-west-2", 0.296}, {"eu-west-1", 0.5}},
+			wantUpdates: map[string]int{"": 0, "eu-west-1": 1, "eu-west-2": 2},
+		},
+		{
+			name:        "fixed schedule",
+			schedule:    FIXED,
+			updates:     []update{{"eu-west-1", 0.5}, {"eu-west-1", 0.5}},
+			wantUpdates: map[string]int{"eu-west-1": 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			epsilonData := make(map[string]FunctionExploration)
+			for i, update := range test.updates {
+				got, err := UpdateEpsilon(epsilonData, "Function1", update.region, test.schedule, now)
+				if err != nil {
+					t.Fatalf("update %d: %v", i, err)
+				}
+				if math.Abs(got-update.want) > 1e-9 {
+					t.Errorf("update %d in %q: epsilon %v, want %v", i, update.region, got, update.want)
+				}
+			}
+			state, exists := epsilonData["function1"]
+			if !exists {
+				t.Fatalf("no state under the lowercased function name: %v", epsilonData)
+			}
+			for region, want := range test.wantUpdates {
+				got := state.Updates
+				if region != "" {
+					got = state.Regions[region].Updates
+				}
+				if got != want {
+					t.Errorf("%q has %d updates, want %d", region, got, want)
+				}
+			}
+			for region := range state.Regions {
+				if _, expected := test.wantUpdates[region]; !expected {
+					t.Errorf("unexpected region state %q", region)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateEpsilonUnknownSchedule(t *testing.T) {
+	if _, err := UpdateEpsilon(make(map[string]FunctionExploration), "function1", "eu-west-1", "NOPE", time.Now()); err == nil {
+		t.Error("expected an error for an unknown schedule")
+	}
+}
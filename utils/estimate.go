@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"radsched/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory billed for functions that do not declare theirs
+const defaultMemoryMB = 128
+
+// Per-decision inputs the policies share to estimate a function's latency at a location
+type latencyEstimator struct {
+	function   common.FunctionInfo
+	rtts       *rttModel
+	profiles   FunctionProfiles
+	coldStarts ColdStartPenalties
+	bandwidth  map[string]float64
+	now        time.Time
+	state      *PolicyState
+}
+
+func newLatencyEstimator(function common.FunctionInfo, rtts *rttModel, opts PolicyOptions) (*latencyEstimator, error) {
+	profiles, err := LoadExecutionProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load execution profiles: %v", err)
+	}
+	coldStarts, err := LoadColdStartPenalties()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cold start penalties: %v", err)
+	}
+	bandwidth, err := GetBandwidth()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bandwidth data: %v", err)
+	}
+	return &latencyEstimator{
+		function:   function,
+		rtts:       rtts,
+		profiles:   profiles[strings.ToLower(function.FunctionName)],
+		coldStarts: coldStarts,
+		bandwidth:  bandwidth,
+		now:        opts.now(),
+		state:      opts.State,
+	}, nil
+}
+
+// Estimate for running at an edge. Reads are served speculatively from the edge
+// and validated with the primary in one round trip that overlaps execution, while
+// each sequential write waits for its own round trip. The request and response
+// take time to cross the client link, and a cold instance adds its expected
+// start-up penalty. Links that were not measured use their coordinate estimate;
+// unreachable and unknown links are excluded or penalized as configured.
+func (e *latencyEstimator) edgeCandidate(edge string) common.CandidateInfo {
+	model := GetConfig().LatencyModel
+	executionTime := e.profiles.ExecutionTime(e.function, edge)
+	clientRTT := e.rtts.clientRTT(edge)
+	edgeRTT := e.rtts.edgeRTT(e.function.Datacenter, edge)
+	clientToEdge, edgeToDatacenter := math.Max(clientRTT.RTT, 0), math.Max(edgeRTT.RTT, 0)
+	coldProbability, coldPenalty := e.coldStart(edge)
+
+	stateTime := e.function.StateAccess.Writes() * edgeToDatacenter
+	validation := edgeToDatacenter + model.ValidationOverheadMs
+	transfer := transferTime(e.function.Payload, linkBandwidth(edge, e.bandwidth))
+	candidate := common.CandidateInfo{
+		Location:        edge,
+		ClientRTT:       clientRTT.RTT,
+		EdgeRTT:         edgeRTT.RTT,
+		RTTStatus:       string(worseStatus(clientRTT.Status, edgeRTT.Status)),
+		Uncertainty:     clientRTT.Uncertainty + edgeRTT.Uncertainty,
+		ExecutionTime:   executionTime,
+		StateTime:       stateTime,
+		Validation:      validation,
+		TransferTime:    transfer,
+		ColdProbability: coldProbability,
+		ColdPenalty:     coldPenalty,
+		Estimate:        clientToEdge + transfer + max(executionTime+stateTime, validation) + coldProbability*coldPenalty,
+	}
+
+	reason := unusableLinkReason("client", clientRTT.Status)
+	if reason == "" {
+		reason = unusableLinkReason("datacenter", edgeRTT.Status)
+	}
+	if reason != "" {
+		if model.Unreachable == "exclude" {
+			candidate.Filtered = true
+			candidate.Reason = reason
+		} else {
+			candidate.Estimate += model.UnreachablePenaltyMs
+			candidate.Reason = "penalized: " + reason
+		}
+	}
+	return candidate
+}
+
+// Marks a candidate eligible if it was not filtered and beats the datacenter.
+// Every policy decides eligibility here so they agree on ties.
+func markEligible(candidate *common.CandidateInfo, datacenterRuntime float64) {
+	if candidate.Filtered {
+		return
+	}
+	candidate.Eligible = candidate.Estimate < datacenterRuntime
+	if !candidate.Eligible {
+		candidate.Reason = "slower than datacenter"
+	}
+}
+
+// Why a link to peer rules a candidate out, or "" if it is usable
+func unusableLinkReason(peer string, status RTTStatus) string {
+	switch status {
+	case RTTUnreachable:
+		return peer + " unreachable"
+	case RTTUnknown:
+		return "no RTT to " + peer
+	}
+	return ""
+}
+
+// Datacenters and link problems already warned about, so a simulation or a
+// long-running server warns once rather than on every estimate
+var unusableDatacenterWarnings sync.Map
+
+// Estimate for running in the function's primary datacenter, where every state
+// access is local. An unmeasured client link uses its coordinate estimate. The
+// datacenter is the fallback and is never excluded, so an unreachable or
+// unknown client link is always penalized and produces a warning, once per
+// datacenter and problem.
+func (e *latencyEstimator) datacenterRuntime() float64 {
+	model := GetConfig().LatencyModel
+	datacenter := e.function.Datacenter
+	clientRTT := e.rtts.clientRTT(datacenter)
+	clientToDatacenter := math.Max(clientRTT.RTT, 0)
+	if reason := unusableLinkReason("client", clientRTT.Status); reason != "" {
+		if _, warned := unusableDatacenterWarnings.LoadOrStore(datacenter+": "+reason, true); !warned {
+			log.Printf("Warning: datacenter %s has %s, adding %.0f ms", datacenter, reason, model.UnreachablePenaltyMs)
+		}
+		clientToDatacenter += model.UnreachablePenaltyMs
+	}
+	coldProbability, coldPenalty := e.coldStart(datacenter)
+	stateTime := float64(e.function.StateAccess.Accesses()) * model.DatacenterAccessMs
+	transfer := transferTime(e.function.Payload, linkBandwidth(datacenter, e.bandwidth))
+	return clientToDatacenter + transfer + e.profiles.ExecutionTime(e.function, datacenter) + stateTime + coldProbability*coldPenalty
+}
+
+// Chance the location has no warm instance at the time of the decision, and
+// the cost if it has none. Simulations track warmth in their own state.
+func (e *latencyEstimator) coldStart(location string) (float64, float64) {
+	var lastInvoked time.Time
+	if e.state != nil {
+		lastInvoked = e.state.LastInvoked[strings.ToLower(e.function.FunctionName)][location]
+	} else if profile := e.profiles[strings.ToLower(location)]; profile != nil {
+		lastInvoked = profile.LastActive()
+	}
+	return expectedColdStart(e.function, location, lastInvoked, e.now, e.coldStarts)
+}
+
+// Price in USD of one invocation at a location, from the region catalog's
+// pricing; "" if the location has none
+func (e *latencyEstimator) cost(location string) string {
+	region, known := GetRegionCatalog().Get(location)
+	if !known || region.Pricing == nil {
+		return ""
+	}
+	memoryMB := e.function.MemoryMB
+	if memoryMB == 0 {
+		memoryMB = defaultMemoryMB
+	}
+	gbSeconds := float64(memoryMB) / 1024 * e.profiles.ExecutionTime(e.function, location) / 1000
+	return fmt.Sprintf("%.10f", region.Pricing.PerRequestUSD+gbSeconds*region.Pricing.PerGBSecondUSD)
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+
+	"radsched/common"
+)
+
+func TestMarkEligible(t *testing.T) {
+	const datacenterRuntime = 100
+	tests := []struct {
+		name       string
+		candidate  common.CandidateInfo
+		eligible   bool
+		wantReason string
+	}{
+		{"faster", common.CandidateInfo{Estimate: 99}, true, ""},
+		{"tie", common.CandidateInfo{Estimate: 100}, false, "slower than datacenter"},
+		{"slower", common.CandidateInfo{Estimate: 101}, false, "slower than datacenter"},
+		{"penalized but faster", common.CandidateInfo{Estimate: 50, Reason: "penalized: no RTT to client"}, true, "penalized: no RTT to client"},
+		{"filtered", common.CandidateInfo{Estimate: 50, Filtered: true, Reason: "client unreachable"}, false, "client unreachable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			candidate := test.candidate
+			markEligible(&candidate, datacenterRuntime)
+			if candidate.Eligible != test.eligible || candidate.Reason != test.wantReason {
+				t.Errorf("eligible %t with reason %q, want %t with %q", candidate.Eligible, candidate.Reason, test.eligible, test.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Monte Carlo draws used to estimate Thompson sampling's choice probabilities
+const thompsonDraws = 1000
+
+// Probability a policy would give each location for a logged decision
+type ActionDistribution func(record DecisionRecord) map[string]float64
+
+// Off-policy estimate of one target policy over a decision log
+type EvaluationResult struct {
+	Target             string
+	Decisions          int     // logged decisions with an outcome and a positive propensity
+	Matched            int     // decisions the target could have made as well
+	EffectiveSamples   float64 // (sum of weights)^2 / sum of squared weights
+	IPSLatency         float64 // inverse propensity scoring estimate of mean latency
+	SNIPSLatency       float64 // self-normalized estimate, lower variance
+	IPSInconsistency   float64
+	SNIPSInconsistency float64
+}
+
+// Parses a target policy: "logged", "greedy", "latency", "epsilon:<rate>" or "thompson"
+func ParseTargetPolicy(spec string, seed int64) (ActionDistribution, error) {
+	name, argument, _ := strings.Cut(strings.ToLower(spec), ":")
+	switch name {
+	case "logged":
+		return func(record DecisionRecord) map[string]float64 {
+			return epsilonGreedyDistribution(record, record.Epsilon)
+		}, nil
+	case "greedy":
+		return func(record DecisionRecord) map[string]float64 {
+			return epsilonGreedyDistribution(record, 0)
+		}, nil
+	case "latency":
+		return func(record DecisionRecord) map[string]float64 {
+			return singleAction(record, argminCandidate(eligibleCandidates(record), func(c DecisionCandidate) float64 { return c.Estimate }))
+		}, nil
+	case "epsilon":
+		epsilon, err := strconv.ParseFloat(argument, 64)
+		if err != nil || epsilon < 0 || epsilon > 1 {
+			return nil, fmt.Errorf("epsilon target needs a rate in [0, 1], got %q", argument)
+		}
+		return func(record DecisionRecord) map[string]float64 {
+			return epsilonGreedyDistribution(record, epsilon)
+		}, nil
+	case "thompson":
+		return thompsonDistribution(seed)
+	}
+	return nil, fmt.Errorf("unknown target policy %s (expected logged, greedy, latency, epsilon:<rate> or thompson)", spec)
+}
+
+// Estimates how each target would have done on the logged decisions, after an
+// "observed" row with the logged policy's own results. Decisions without an
+// outcome or a propensity are left out.
+func Evaluate(records []DecisionRecord, targets []string, seed int64) ([]EvaluationResult, error) {
+	var usable []DecisionRecord
+	for _, record := range records {
+		if record.Outcome != nil && record.Propensity > 0 {
+			usable = append(usable, record)
+		}
+	}
+
+	results := []EvaluationResult{evaluateWeights("observed", usable, func(DecisionRecord) float64 { return 1 })}
+	for _, target := range targets {
+		distribution, err := ParseTargetPolicy(target, seed)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, evaluateWeights(target, usable, func(record DecisionRecord) float64 {
+			return distribution(record)[record.Location] / record.Propensity
+		}))
+	}
+	return results, nil
+}
+
+func evaluateWeights(target string, records []DecisionRecord, weight func(DecisionRecord) float64) EvaluationResult {
+	result := EvaluationResult{Target: target, Decisions: len(records)}
+	var weights, squares, latency, inconsistency float64
+	for _, record := range records {
+		w := weight(record)
+		if w > 0 {
+			result.Matched++
+		}
+		weights += w
+		squares += w * w
+		latency += w * record.Outcome.Latency
+		if record.Outcome.Inconsistent != nil && *record.Outcome.Inconsistent {
+			inconsistency += w
+		}
+	}
+	if len(records) == 0 || weights == 0 {
+		return result
+	}
+	n := float64(len(records))
+	result.EffectiveSamples = weights * weights / squares
+	result.IPSLatency = latency / n
+	result.SNIPSLatency = latency / weights
+	result.IPSInconsistency = inconsistency / n
+	result.SNIPSInconsistency = inconsistency / weights
+	return result
+}
+
+// Candidates the logged policy could choose from, by location
+func eligibleCandidates(record DecisionRecord) []DecisionCandidate {
+	var eligible []DecisionCandidate
+	for _, candidate := range record.Candidates {
+		if candidate.Eligible {
+			eligible = append(eligible, candidate)
+		}
+	}
+	sort.SliceStable(eligible, func(i, j int) bool { return eligible[i].Location < eligible[j].Location })
+	return eligible
+}
+
+// Location with the lowest score, first by location on ties
+func argminCandidate(candidates []DecisionCandidate, score func(DecisionCandidate) float64) string {
+	best, bestScore := "", math.MaxFloat64
+	for _, candidate := range candidates {
+		if s := score(candidate); s < bestScore {
+			best, bestScore = candidate.Location, s
+		}
+	}
+	return best
+}
+
+// All mass on one location, or on the logged one when nothing was eligible,
+// where every policy falls back to the datacenter
+func singleAction(record DecisionRecord, location string) map[string]float64 {
+	if location == "" {
+		location = record.Location
+	}
+	return map[string]float64{location: 1}
+}
+
+// Epsilon-greedy over the eligible candidates, exploiting the lowest weighted estimate
+func epsilonGreedyDistribution(record DecisionRecord, epsilon float64) map[string]float64 {
+	eligible := eligibleCandidates(record)
+	greedy := argminCandidate(eligible, func(c DecisionCandidate) float64 { return c.Estimate * c.Weight })
+	distribution := singleAction(record, greedy)
+	if len(eligible) == 0 {
+		return distribution
+	}
+	distribution[greedy] = 1 - epsilon
+	for _, candidate := range eligible {
+		distribution[candidate.Location] += epsilon / float64(len(eligible))
+	}
+	return distribution
+}
+
+// Thompson sampling over the consistency failure ratio of each eligible edge,
+// with a Beta posterior from the edge consistency statistics in the data
+// directory, choosing the lowest estimate times the sampled ratio
+func thompsonDistribution(seed int64) (ActionDistribution, error) {
+	var stats map[string]map[string]FunctionStats
+	if _, err := readDataFile(DataPath(EdgeConsistencyFile), &stats); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read edge-function consistency cache: %v", err)
+	}
+	rng := rand.New(rand.NewSource(seed))
+	return func(record DecisionRecord) map[string]float64 {
+		eligible := eligibleCandidates(record)
+		if len(eligible) == 0 {
+			return singleAction(record, "")
+		}
+		distribution := make(map[string]float64)
+		for draw := 0; draw < thompsonDraws; draw++ {
+			best, bestScore := "", math.MaxFloat64
+			for _, candidate := range eligible {
+				edgeStats := stats[candidate.Location][record.Function]
+				ratio := sampleBeta(rng, float64(edgeStats.NumFailure+1), float64(edgeStats.NumAttempts-edgeStats.NumFailure+1))
+				if score := candidate.Estimate * ratio; score < bestScore {
+					best, bestScore = candidate.Location, score
+				}
+			}
+			distribution[best] += 1.0 / thompsonDraws
+		}
+		return distribution
+	}, nil
+}
+
+func sampleBeta(rng *rand.Rand, a, b float64) float64 {
+	x := sampleGamma(rng, a)
+	return x / (x + sampleGamma(rng, b))
+}
+
+// Marsaglia and Tsang's method, for shape >= 1
+func sampleGamma(rng *rand.Rand, shape float64) float64 {
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// A logged epsilon-greedy decision over edges a (estimate 10) and b (estimate 20)
+func loggedDecision(location string, propensity float64, latency float64, inconsistent bool) DecisionRecord {
+	return DecisionRecord{
+		Function:   "function1",
+		Epsilon:    0.2,
+		Propensity: propensity,
+		Location:   location,
+		Candidates: []DecisionCandidate{
+			{Location: "a", Estimate: 10, Weight: 1, Eligible: true},
+			{Location: "b", Estimate: 20, Weight: 1, Eligible: true},
+			{Location: "c", Estimate: 5, Weight: 1, Reason: "slower than datacenter"},
+		},
+		Outcome: &DecisionOutcome{Latency: latency, Inconsistent: &inconsistent},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	// propensities of the logged policy: a is greedy, so 0.8 + 0.1, and b gets 0.1
+	records := []DecisionRecord{
+		loggedDecision("a", 0.9, 10, false),
+		loggedDecision("a", 0.9, 30, true),
+		loggedDecision("b", 0.1, 20, false),
+		loggedDecision("b", 0.1, 40, true),
+		{Function: "function1", Location: "a", Propensity: 0.9},             // no outcome
+		{Function: "function1", Location: "a", Outcome: &DecisionOutcome{}}, // no propensity
+	}
+	results, err := Evaluate(records, []string{"logged", "greedy", "latency", "epsilon:1"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		want EvaluationResult
+	}{
+		{EvaluationResult{Target: "observed", Decisions: 4, Matched: 4, EffectiveSamples: 4,
+			IPSLatency: 25, SNIPSLatency: 25, IPSInconsistency: 0.5, SNIPSInconsistency: 0.5}},
+		// every weight is 1 when the target is the logged policy
+		{EvaluationResult{Target: "logged", Decisions: 4, Matched: 4, EffectiveSamples: 4,
+			IPSLatency: 25, SNIPSLatency: 25, IPSInconsistency: 0.5, SNIPSInconsistency: 0.5}},
+		// weights 1/0.9 on the decisions at a and 0 on those at b
+		{EvaluationResult{Target: "greedy", Decisions: 4, Matched: 2, EffectiveSamples: 2,
+			IPSLatency: 40 / 0.9 / 4, SNIPSLatency: 20, IPSInconsistency: 1 / 0.9 / 4, SNIPSInconsistency: 0.5}},
+		{EvaluationResult{Target: "latency", Decisions: 4, Matched: 2, EffectiveSamples: 2,
+			IPSLatency: 40 / 0.9 / 4, SNIPSLatency: 20, IPSInconsistency: 1 / 0.9 / 4, SNIPSInconsistency: 0.5}},
+		// uniform over a and b: weights 0.5/0.9 at a and 0.5/0.1 at b
+		{EvaluationResult{Target: "epsilon:1", Decisions: 4, Matched: 4,
+			EffectiveSamples: math.Pow(2*0.5/0.9+2*5, 2) / (2*math.Pow(0.5/0.9, 2) + 2*25),
+			IPSLatency:       (40*0.5/0.9 + 60*5) / 4, SNIPSLatency: (40*0.5/0.9 + 60*5) / (2*0.5/0.9 + 2*5),
+			IPSInconsistency: (0.5/0.9 + 5) / 4, SNIPSInconsistency: (0.5/0.9 + 5) / (2*0.5/0.9 + 2*5)}},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("%d results, want %d", len(results), len(tests))
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for i, test := range tests {
+		got, want := results[i], test.want
+		if got.Target != want.Target || got.Decisions != want.Decisions || got.Matched != want.Matched ||
+			!near(got.EffectiveSamples, want.EffectiveSamples) ||
+			!near(got.IPSLatency, want.IPSLatency) || !near(got.SNIPSLatency, want.SNIPSLatency) ||
+			!near(got.IPSInconsistency, want.IPSInconsistency) || !near(got.SNIPSInconsistency, want.SNIPSInconsistency) {
+			t.Errorf("got  %+v\nwant %+v", got, want)
+		}
+	}
+}
+
+func TestEvaluateNoMatches(t *testing.T) {
+	results, err := Evaluate([]DecisionRecord{loggedDecision("b", 0.1, 20, false)}, []string{"greedy"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := results[1]; got.Matched != 0 || got.IPSLatency != 0 || got.SNIPSLatency != 0 || got.EffectiveSamples != 0 {
+		t.Errorf("a target that never agrees with the log got %+v", got)
+	}
+}
+
+func TestParseTargetPolicy(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{"logged", true},
+		{"Greedy", true},
+		{"latency", true},
+		{"epsilon:0.05", true},
+		{"epsilon:0", true},
+		{"epsilon:1", true},
+		{"epsilon:1.5", false},
+		{"epsilon:-0.1", false},
+		{"epsilon", false},
+		{"thompson", true},
+		{"random", false},
+	}
+	for _, test := range tests {
+		_, err := ParseTargetPolicy(test.spec, 1)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseTargetPolicy(%q) error %v, want valid %t", test.spec, err, test.valid)
+		}
+	}
+}
+
+func TestEpsilonGreedyDistribution(t *testing.T) {
+	tests := []struct {
+		epsilon float64
+		want    map[string]float64
+	}{
+		{0, map[string]float64{"a": 1, "b": 0}},
+		{0.2, map[string]float64{"a": 0.9, "b": 0.1}},
+		{1, map[string]float64{"a": 0.5, "b": 0.5}},
+	}
+	for _, test := range tests {
+		got := epsilonGreedyDistribution(loggedDecision("a", 1, 10, false), test.epsilon)
+		for location, want := range test.want {
+			if math.Abs(got[location]-want) > 1e-9 {
+				t.Errorf("epsilon %v: %s has %v, want %v", test.epsilon, location, got[location], want)
+			}
+		}
+		if got["c"] != 0 {
+			t.Errorf("epsilon %v: ineligible c has %v", test.epsilon, got["c"])
+		}
+	}
+
+	// with nothing eligible every policy falls back to the logged location
+	record := DecisionRecord{Location: "datacenter", Candidates: []DecisionCandidate{{Location: "a", Estimate: 1}}}
+	if got := epsilonGreedyDistribution(record, 0.3); len(got) != 1 || got["datacenter"] != 1 {
+		t.Errorf("fallback distribution %v", got)
+	}
+}
+
+func TestSampleGamma(t *testing.T) {
+	const draws = 200000
+	tests := []float64{1, 2.5, 10, 100}
+	for _, shape := range tests {
+		rng := rand.New(rand.NewSource(1))
+		var sum, squares float64
+		for i := 0; i < draws; i++ {
+			x := sampleGamma(rng, shape)
+			if x <= 0 {
+				t.Fatalf("shape %v: non-positive sample %v", shape, x)
+			}
+			sum += x
+			squares += x * x
+		}
+		// a gamma with scale 1 has mean and variance equal to its shape
+		mean := sum / draws
+		variance := squares/draws - mean*mean
+		if math.Abs(mean-shape) > 0.02*shape {
+			t.Errorf("shape %v: mean %v", shape, mean)
+		}
+		if math.Abs(variance-shape) > 0.05*shape {
+			t.Errorf("shape %v: variance %v", shape, variance)
+		}
+	}
+}
+
+func TestSampleBeta(t *testing.T) {
+	const draws = 100000
+	tests := []struct{ a, b float64 }{{1, 1}, {2, 8}, {30, 10}}
+	for _, test := range tests {
+		rng := rand.New(rand.NewSource(1))
+		var sum float64
+		for i := 0; i < draws; i++ {
+			x := sampleBeta(rng, test.a, test.b)
+			if x < 0 || x > 1 {
+				t.Fatalf("Beta(%v, %v) sample %v outside [0, 1]", test.a, test.b, x)
+			}
+			sum += x
+		}
+		if mean, want := sum/draws, test.a/(test.a+test.b); math.Abs(mean-want) > 0.01 {
+			t.Errorf("Beta(%v, %v) mean %v, want %v", test.a, test.b, mean, want)
+		}
+	}
+}
@@ -1,20 +1,25 @@
 package utils
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 	"radsched/common"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// data files written by bootstrap, relative to the configured data directory
+const (
+	FunctionRegistryFile    = "function_registry.json"
+	ClientEdgeRTTFile       = "client_edge_rtts.json"
+	EdgeDatacenterRTTFile   = "edge_datacenter_rtts.json"
+	FunctionConsistencyFile = "function_consistency.json"
+	EdgeConsistencyFile     = "edge_function_consistency.json"
+	EpsilonFile             = "epsilon.json"
 )
 
 
@@ -33,39 +38,33 @@ func LoadFunctions() ([]common.FunctionInfo, error) {
 }
 
 func StoreFunctions(functions []common.FunctionInfo ) (error) {
-	file, err := os.Create("/Users/yoni_mindel/Desktop/Thesis/radsched/function_registry.json")
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ") 
-	if err := encoder.Encode(functions); err != nil {
-		return err
-	}
-	return nil
+	return writeDataFile(DataPath(FunctionRegistryFile), functions)
 }
 
+// Measures the client's RTT to every probed location through its provider.
+// Locations a provider could not reach are left out.
 func GetClientToEdgeRTT() ([]common.LocationInfo, error) {
-	cmd := exec.Command("/usr/local/bin/python3", "/Users/yoni_mindel/Desktop/Thesis/radsched/ping_edges.py")
-	output, err := cmd.Output()
-	if err != nil {
-		log.Fatalf("Failed to execute ping_regions.py: %v", err)
-		return nil, err
-	}
-
-	var rttData map[string]float64
-	if err := json.Unmarshal(output, &rttData); err != nil {
-		log.Fatalf("Failed to unmarshal RTT data: %v", err)
-		return nil, err
+	rttData := make(map[string]float64)
+	for name, regions := range probedRegionsByProvider() {
+		provider, err := GetProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		measured, err := provider.ProbeClient(regions)
+		if err != nil {
+			log.Printf("Failed to probe %s locations from the client: %v", name, err)
+			continue
+		}
+		for location, rtt := range measured {
+			rttData[location] = rtt
+		}
 	}
 
 	var locations []common.LocationInfo
-	for region, rtt := range rttData {
+	for _, region := range sortedKeys(rttData) {
 		locations = append(locations, common.LocationInfo{
 			LocationName: region,
-			RoundTripTime: strings.TrimSpace(fmt.Sprintf("%.2f ms", rtt)),
+			RoundTripTime: strings.TrimSpace(fmt.Sprintf("%.2f ms", rttData[region])),
 		})
 	}
 
@@ -73,32 +72,14 @@ func GetClientToEdgeRTT() ([]common.LocationInfo, error) {
 }
 
 func StoreLocations(locations []common.LocationInfo) error {
-	file, err := os.Create("/Users/yoni_mindel/Desktop/Thesis/radsched/client_edge_rtts.json")
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ") // Pretty-print the JSON
-	if err := encoder.Encode(locations); err != nil {
-		return err
-	}
-
-	return nil
+	return writeDataFile(DataPath(ClientEdgeRTTFile), locations)
 }
 
 func GetFunctionsAsMap()(map[string]common.FunctionInfo, error) {
-	file, err := os.Open("/Users/yoni_mindel/Desktop/Thesis/radsched/function_registry.json")
+	functionList, err := GetFunctionsAsList()
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	var functionList []common.FunctionInfo
-	if err := json.NewDecoder(file).Decode(&functionList); err != nil {
-		return nil, err
-	}
 
 	functionMap := make(map[string]common.FunctionInfo)
 	for _, function := range functionList {
@@ -109,14 +90,8 @@ func GetFunctionsAsMap()(map[string]common.FunctionInfo, error) {
 }
 
 func GetFunctionsAsList()([]common.FunctionInfo, error) {
-	file, err := os.Open("/Users/yoni_mindel/Desktop/Thesis/radsched/function_registry.json")
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
 	var functionList []common.FunctionInfo
-	if err := json.NewDecoder(file).Decode(&functionList); err != nil {
+	if _, err := readDataFile(DataPath(FunctionRegistryFile), &functionList); err != nil {
 		return nil, err
 	}
 
@@ -124,22 +99,21 @@ func GetFunctionsAsList()([]common.FunctionInfo, error) {
 }
 
 func GetLocations()(map[string]float64, error) {
-	file, err := os.Open("/Users/yoni_mindel/Desktop/Thesis/radsched/client_edge_rtts.json")
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
 	var locationList []common.LocationInfo
-	if err := json.NewDecoder(file).Decode(&locationList); err != nil {
+	if _, err := readDataFile(DataPath(ClientEdgeRTTFile), &locationList); err != nil {
 		return nil, err
 	}
 
 	locationMap := make(map[string]float64)
 	for _, location := range locationList {
+		// ping_edges.py writes -1 for regions it could not reach
 		rtt, err := strconv.ParseFloat(strings.Split(location.RoundTripTime, " ")[0], 64)
 		if (err != nil) {
-			log.Fatalf("Error converting string to float64: %v", err)
+			log.Printf("Unreadable RTT %q for %s, treating it as unreachable", location.RoundTripTime, location.LocationName)
+			rtt = UnreachableRTT
+		}
+		if IsUnreachable(rtt) {
+			rtt = UnreachableRTT
 		}
 		locationMap[strings.TrimSpace(strings.ToLower(location.LocationName))] = rtt
 	}
@@ -148,14 +122,8 @@ func GetLocations()(map[string]float64, error) {
 }
 
 func GetEdges()(map[string]map[string]float64, error) {
-	edges, err := os.Open("/Users/yoni_mindel/Desktop/Thesis/radsched/edge_datacenter_rtts.json")
-	if err != nil {
-		return nil, err
-	}
-	defer edges.Close()
-
 	edgesMap := make(map[string]map[string]float64)
-	if err := json.NewDecoder(edges).Decode(&edgesMap); err != nil {
+	if _, err := readDataFile(DataPath(EdgeDatacenterRTTFile), &edgesMap); err != nil {
 		return nil, err
 	}
 
@@ -172,81 +140,40 @@ func GetEdges()(map[string]map[string]float64, error) {
 	return edgesLowerMap, nil
 }
 
-func GetEdgeToDataCenterRTT() (map[string]map[string]interface{}, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		return nil, err
+// Measures the RTTs from every probed location to the others, as seen from
+// that location by its provider
+func GetEdgeToDataCenterRTT() (map[string]map[string]float64, error) {
+	catalog := GetRegionCatalog()
+	var probed []common.Region
+	for _, id := range catalog.Probed() {
+		region, _ := catalog.Get(id)
+		probed = append(probed, region)
 	}
-	client := lambda.NewFromConfig(cfg)
 
-	allRTTData := make(map[string]map[string]interface{})
+	allRTTData := make(map[string]map[string]float64)
 
-	for _, region := range common.Datacenters {
-		data, err := invokeLambdaInRegion(client, region)
+	// a region that fails is left out and reported as unmeasured
+	for _, region := range probed {
+		provider, err := GetProvider(region.Provider)
 		if err != nil {
 			return nil, err
 		}
-		allRTTData[region] = data
-	}
-
-	return allRTTData, nil
-}
-
-func invokeLambdaInRegion(client *lambda.Client, region string)  (map[string]interface{}, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
-	if err != nil {
-		return nil, err
-	}
-
-	client = lambda.NewFromConfig(cfg) 
-
-	input := &lambda.InvokeInput{
-		FunctionName: aws.String("PingDatacenters"), 
-	}
-
-	output, err := client.Invoke(context.TODO(), input)
-	if err != nil {
-		return nil, err
-	}
-
-	var rttData map[string]interface{}
-	if err := json.Unmarshal(output.Payload, &rttData); err != nil {
-		return nil, err
-	}
-
-	return rttData, nil
-}
-
-func SaveRTTDataToJSON(data map[string]map[string]interface{}) {
-	formattedData := make(map[string]map[string]float64)
-
-	for region, result := range data {
-		bodyStr, ok := result["body"].(string)
-		if !ok {
-			log.Printf("Unexpected body format for region %s", region)
-			continue
-		}
-
-		var rttMap map[string]float64
-		if err := json.Unmarshal([]byte(bodyStr), &rttMap); err != nil {
-			log.Printf("Failed to unmarshal RTT data for region %s: %v", region, err)
+		data, err := provider.ProbeFrom(region, probed)
+		if err != nil {
+			log.Printf("Failed to measure RTTs from %s: %v", region.ID(), err)
 			continue
 		}
-
-		formattedData[region] = rttMap
+		allRTTData[region.ID()] = data
 	}
 
-	file, err := os.Create("/Users/yoni_mindel/Desktop/Thesis/radsched/edge_datacenter_rtts.json")
-	if err != nil {
-		log.Fatalf("Failed to create RTT data file: %v", err)
-	}
-	defer file.Close()
+	return allRTTData, nil
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(formattedData); err != nil {
-		log.Fatalf("Failed to write RTT data to JSON: %v", err)
+func SaveRTTDataToJSON(data map[string]map[string]float64) error {
+	if err := writeDataFile(DataPath(EdgeDatacenterRTTFile), data); err != nil {
+		return fmt.Errorf("failed to write RTT data to JSON: %v", err)
 	}
+	return nil
 }
 
 
@@ -259,15 +186,9 @@ type ConsistencyData struct {
 }
 
 func getConsistencyWeight(edge string, function string) (float64, error) {
-	file, err := os.Open("/Users/yoni_mindel/Desktop/Thesis/radsched/edge_function_consistency.json")
-	if err != nil {
-		return -1.0, fmt.Errorf("failed to open edge-function consistency cache: %v", err)
-	}
-	defer file.Close()
-
 	var data map[string]map[string]FunctionStats
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
-		return -1.0, fmt.Errorf("failed to parse edge-function consistency JSON: %v", err)
+	if _, err := readDataFile(DataPath(EdgeConsistencyFile), &data); err != nil {
+		return -1.0, fmt.Errorf("failed to read edge-function consistency cache: %v", err)
 	}
 
 	funcsForEdge, edgeExists := data[edge]
@@ -290,15 +211,9 @@ type FunctionStats struct {
 }
 
 func FetchHitRatioByFunction() (map[string]FunctionStats, error) {
-	file, err := os.Open("/Users/yoni_mindel/Desktop/Thesis/radsched/function_consistency.json")
-	if err != nil {
-		return nil, fmt.Errorf("failed to open consistency cache: %v", err)
-	}
-	defer file.Close()
-
 	var functionData map[string]FunctionStats
-	if err := json.NewDecoder(file).Decode(&functionData); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %v", err)
+	if _, err := readDataFile(DataPath(FunctionConsistencyFile), &functionData); err != nil {
+		return nil, fmt.Errorf("failed to read consistency cache: %v", err)
 	}
 
 	return functionData, nil
@@ -306,83 +221,72 @@ func FetchHitRatioByFunction() (map[string]FunctionStats, error) {
 
 
 func FetchHitRatioByFunctionRemote() (map[string]FunctionStats, error) {
-	url := "http://54.219.54.16/cgi-bin/hit_ratio_v2.py"
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch data: %v", err)
+	var functionData map[string]FunctionStats
+	if err := fetchConsistencyStats(GetConfig().Consistency.FunctionStatsURL, &functionData); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return functionData, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+func FetchHitRatioByEdgeRemote() (map[string]map[string]FunctionStats, error) {
+	var edgefunctionData map[string]map[string]FunctionStats
+	if err := fetchConsistencyStats(GetConfig().Consistency.EdgeStatsURL, &edgefunctionData); err != nil {
+		return nil, err
 	}
+	return edgefunctionData, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// Fetches a hit ratio payload from the consistency server and decodes it into out
+func fetchConsistencyStats(url string, out interface{}) error {
+	cfg := GetConfig().Consistency
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+		return fmt.Errorf("failed to build request: %v", err)
 	}
-
-	var functionData map[string]FunctionStats
-	err = json.Unmarshal(body, &functionData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %v", err)
+	if cfg.AuthToken != "" {
+		req.Header.Set(cfg.AuthHeader, consistencyAuthValue(cfg))
 	}
 
-	return functionData, nil
-}
-
-func FetchHitRatioByEdgeRemote() (map[string]map[string]FunctionStats, error) {
-	url := "http://54.219.54.16/cgi-bin/hit_ratio.py"
-	resp, err := http.Get(url)
+	client := &http.Client{Timeout: time.Duration(cfg.TimeoutMs) * time.Millisecond}
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch data: %v", err)
+		return fmt.Errorf("failed to fetch data: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+		return fmt.Errorf("failed to read response body: %v", err)
 	}
 
-	var edgefunctionData map[string]map[string]FunctionStats
-	err = json.Unmarshal(body, &edgefunctionData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %v", err)
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse JSON: %v", err)
 	}
-
-	return edgefunctionData, nil
+	return nil
 }
 
-func StoreFunctionStats(stats map[string]FunctionStats) error {
-	file, err := os.Create("/Users/yoni_mindel/Desktop/Thesis/radsched/function_consistency.json")
-	if err != nil {
-		return fmt.Errorf("failed to create function consistency file: %v", err)
+// Bearer scheme for the standard Authorization header, raw token for custom headers
+func consistencyAuthValue(cfg ConsistencyConfig) string {
+	if strings.EqualFold(cfg.AuthHeader, "Authorization") {
+		return "Bearer " + cfg.AuthToken
 	}
-	defer file.Close()
+	return cfg.AuthToken
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(stats); err != nil {
-		return fmt.Errorf("failed to encode function consistency data: %v", err)
+func StoreFunctionStats(stats map[string]FunctionStats) error {
+	if err := writeDataFile(DataPath(FunctionConsistencyFile), stats); err != nil {
+		return fmt.Errorf("failed to write function consistency data: %v", err)
 	}
 	return nil
 }
 
 func StoreFunctionStatsByEdge(stats map[string]map[string]FunctionStats) error {
-	file, err := os.Create("/Users/yoni_mindel/Desktop/Thesis/radsched/edge_function_consistency.json")
-	if err != nil {
-		return fmt.Errorf("failed to create edge-function consistency file: %v", err)
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(stats); err != nil {
-		return fmt.Errorf("failed to encode edge-function consistency data: %v", err)
+	if err := writeDataFile(DataPath(EdgeConsistencyFile), stats); err != nil {
+		return fmt.Errorf("failed to write edge-function consistency data: %v", err)
 	}
 	return nil
 }
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package utils
+
+// No cross-process lock here; callers still hold their in-process mutex
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,319 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"radsched/common"
+	"sort"
+	"strings"
+	"time"
+)
+
+const TraceFile = "trace.jsonl"
+
+// Shape of a synthetic topology and workload
+type GeneratorOptions struct {
+	Regions         int
+	Clients         int
+	Functions       int
+	Start           time.Time
+	Hours           float64
+	RequestsPerHour float64 // mean request rate; the actual rate follows the time of day
+	Jitter          float64 // relative standard deviation of each RTT sample
+	Diurnal         float64 // relative RTT and request rate swing over a day, in [0, 1)
+	Seed            int64
+}
+
+// A generated world, in memory
+type SyntheticTopology struct {
+	opts        GeneratorOptions
+	rng         *rand.Rand
+	regions     common.RegionCatalog
+	clients     []ClientProfile
+	functions   []common.FunctionInfo
+	consistency map[string]map[string]consistencyDrift // edge -> function -> drift
+}
+
+// Failure probability of one edge for one function, drifting over time
+type consistencyDrift struct {
+	base      float64
+	amplitude float64
+	periodH   float64
+	phase     float64
+}
+
+func (d consistencyDrift) at(hours float64) float64 {
+	return math.Min(1, math.Max(0, d.base+d.amplitude*math.Sin(2*math.Pi*hours/d.periodH+d.phase)))
+}
+
+// Picks regions, places clients near them and builds a function mix like
+// common.TEST_FUNCTION_MAP, all from the seed
+func NewSyntheticTopology(opts GeneratorOptions) (*SyntheticTopology, error) {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	var located common.RegionCatalog
+	for _, region := range common.DefaultRegionCatalog() {
+		if region.Location != nil {
+			located = append(located, region)
+		}
+	}
+	if opts.Regions < 2 || opts.Regions > len(located) {
+		return nil, fmt.Errorf("regions must be between 2 and %d, got %d", len(located), opts.Regions)
+	}
+	if opts.Clients < 1 || opts.Functions < 1 {
+		return nil, fmt.Errorf("clients and functions must be positive")
+	}
+	if opts.Hours <= 0 {
+		return nil, fmt.Errorf("hours must be positive, got %v", opts.Hours)
+	}
+	if opts.Jitter < 0 {
+		return nil, fmt.Errorf("jitter must not be negative, got %v", opts.Jitter)
+	}
+	if opts.Diurnal < 0 || opts.Diurnal >= 1 {
+		return nil, fmt.Errorf("diurnal must be in [0, 1), got %v", opts.Diurnal)
+	}
+
+	topology := &SyntheticTopology{opts: opts, rng: rng}
+	for _, i := range rng.Perm(len(located))[:opts.Regions] {
+		region := located[i]
+		region.Edge, region.Primary, region.Probed = true, true, true
+		topology.regions = append(topology.regions, region)
+	}
+	sort.Slice(topology.regions, func(i, j int) bool { return topology.regions[i].Name < topology.regions[j].Name })
+
+	for i := 0; i < opts.Clients; i++ {
+		home := topology.regions[rng.Intn(len(topology.regions))]
+		location := common.GeoPoint{
+			Latitude:  math.Max(-89, math.Min(89, home.Location.Latitude+rng.NormFloat64()*3)),
+			Longitude: home.Location.Longitude + rng.NormFloat64()*3,
+		}
+		topology.clients = append(topology.clients, ClientProfile{
+			ID:       fmt.Sprintf("client-%d", i+1),
+			Region:   home.Name,
+			Location: &location,
+		})
+	}
+
+	// execution times cycle through 5 ms to 3125 ms like the test functions
+	for i := 0; i < opts.Functions; i++ {
+		topology.functions = append(topology.functions, common.FunctionInfo{
+			SchemaVersion: common.FunctionSchemaVersion,
+			FunctionName:  fmt.Sprintf("function%d", i+1),
+			ExecutionTime: common.Duration(time.Duration(math.Pow(5, float64(i%5+1))) * time.Millisecond),
+			Datacenter:    topology.regions[rng.Intn(len(topology.regions))].Name,
+			StateAccess:   common.StateAccessProfile{SequentialAccesses: 1 + rng.Intn(3), WriteFraction: math.Round(rng.Float64()*50) / 100},
+		})
+	}
+
+	topology.consistency = make(map[string]map[string]consistencyDrift)
+	for _, region := range topology.regions {
+		topology.consistency[region.Name] = make(map[string]consistencyDrift)
+		for _, function := range topology.functions {
+			topology.consistency[region.Name][function.FunctionName] = consistencyDrift{
+				base:      0.01 + rng.Float64()*0.3,
+				amplitude: rng.Float64() * 0.1,
+				periodH:   6 + rng.Float64()*42,
+				phase:     rng.Float64() * 2 * math.Pi,
+			}
+		}
+	}
+	return topology, nil
+}
+
+// Multiplier of RTTs and request rate at a time of day, peaking at 14:00 UTC
+func (t *SyntheticTopology) diurnal(at time.Time) float64 {
+	hour := float64(at.Hour()) + float64(at.Minute())/60
+	return 1 + t.opts.Diurnal*math.Sin(2*math.Pi*(hour-8)/24)
+}
+
+// One RTT sample between two points at a time
+func (t *SyntheticTopology) rtt(a common.GeoPoint, b common.GeoPoint, at time.Time) float64 {
+	base := distanceRTT(GreatCircleKm(a, b)) * t.diurnal(at)
+	return math.Max(0.5, base*(1+t.rng.NormFloat64()*t.opts.Jitter))
+}
+
+// RTTs from a client to every region at a time
+func (t *SyntheticTopology) clientRTTs(client ClientProfile, at time.Time) map[string]float64 {
+	rtts := make(map[string]float64)
+	for _, region := range t.regions {
+		rtts[region.Name] = math.Round(t.rtt(*client.Location, *region.Location, at)*100) / 100
+	}
+	return rtts
+}
+
+// RTTs between every pair of regions at a time
+func (t *SyntheticTopology) edgeRTTs(at time.Time) map[string]map[string]float64 {
+	edges := make(map[string]map[string]float64)
+	for _, from := range t.regions {
+		edges[from.Name] = make(map[string]float64)
+		for _, to := range t.regions {
+			rtt := 0.5
+			if from.Name != to.Name {
+				rtt = math.Round(t.rtt(*from.Location, *to.Location, at)*100) / 100
+			}
+			edges[from.Name][to.Name] = rtt
+		}
+	}
+	return edges
+}
+
+// RTTs and consistency failure ratios at the start of every hour of the window
+func (t *SyntheticTopology) Snapshots() NetworkSnapshots {
+	var snapshots NetworkSnapshots
+	end := t.opts.Start.Add(time.Duration(t.opts.Hours * float64(time.Hour)))
+	for at := t.opts.Start; at.Before(end); at = at.Add(time.Hour) {
+		snapshot := NetworkSnapshot{
+			At:          at,
+			Clients:     make(map[string]map[string]float64),
+			Edges:       t.edgeRTTs(at),
+			Consistency: make(map[string]map[string]float64),
+		}
+		for _, client := range t.clients {
+			snapshot.Clients[client.ID] = t.clientRTTs(client, at)
+		}
+		hours := at.Sub(t.opts.Start).Hours()
+		for _, region := range t.regions {
+			snapshot.Consistency[region.Name] = make(map[string]float64)
+			for _, function := range t.functions {
+				ratio := t.consistency[region.Name][function.FunctionName].at(hours)
+				snapshot.Consistency[region.Name][function.FunctionName] = math.Round(ratio*10000) / 10000
+			}
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// Request trace over the configured window; the rate follows the time of day
+// and popular functions and clients are drawn more often
+func (t *SyntheticTopology) Trace() []TraceRequest {
+	var trace []TraceRequest
+	end := t.opts.Start.Add(time.Duration(t.opts.Hours * float64(time.Hour)))
+	for minute := t.opts.Start; minute.Before(end); minute = minute.Add(time.Minute) {
+		count := poisson(t.rng, t.opts.RequestsPerHour/60*t.diurnal(minute))
+		for i := 0; i < count; i++ {
+			trace = append(trace, TraceRequest{
+				Timestamp: minute.Add(time.Duration(t.rng.Int63n(int64(time.Minute)))),
+				Function:  t.functions[zipf(t.rng, len(t.functions))].FunctionName,
+				Client:    t.clients[zipf(t.rng, len(t.clients))].ID,
+			})
+		}
+	}
+	sort.SliceStable(trace, func(i, j int) bool { return trace[i].Timestamp.Before(trace[j].Timestamp) })
+	return trace
+}
+
+// Writes the topology to dir in the formats bootstrap produces, as measured
+// at the start of the window, along with hourly snapshots of the RTTs and
+// consistency over the window for simulate, the trace and a config pointing at
+// dir. The first client's RTTs become the bootstrap client RTTs.
+func (t *SyntheticTopology) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	snapshots := t.Snapshots()
+	first := snapshots[0]
+
+	for i := range t.clients {
+		t.clients[i].RTTs = first.Clients[t.clients[i].ID]
+		t.clients[i].MeasuredAt = first.At
+	}
+	var locations []common.LocationInfo
+	for _, region := range sortedKeys(t.clients[0].RTTs) {
+		locations = append(locations, common.LocationInfo{
+			LocationName:  region,
+			RoundTripTime: fmt.Sprintf("%.2f ms", t.clients[0].RTTs[region]),
+		})
+	}
+
+	functionStats := make(map[string]FunctionStats)
+	edgeStats := make(map[string]map[string]FunctionStats)
+	for _, region := range t.regions {
+		edge := region.Name
+		edgeStats[edge] = make(map[string]FunctionStats)
+		for _, info := range t.functions {
+			function := info.FunctionName
+			attempts := 100 + t.rng.Intn(900)
+			failures := int(math.Round(float64(attempts) * first.Consistency[edge][function]))
+			stats := FunctionStats{NumAttempts: attempts, NumSuccess: attempts - failures, NumFailure: failures}
+			edgeStats[edge][function] = stats
+			total := functionStats[function]
+			total.NumAttempts += stats.NumAttempts
+			total.NumSuccess += stats.NumSuccess
+			total.NumFailure += stats.NumFailure
+			functionStats[function] = total
+		}
+	}
+
+	files := map[string]interface{}{
+		RegionCatalogFile:       t.regions,
+		ClientProfilesFile:      t.clients,
+		ClientEdgeRTTFile:       locations,
+		EdgeDatacenterRTTFile:   first.Edges,
+		FunctionRegistryFile:    t.functions,
+		FunctionConsistencyFile: functionStats,
+		EdgeConsistencyFile:     edgeStats,
+		NetworkSnapshotsFile:    snapshots,
+	}
+	for name, data := range files {
+		if err := writeDataFile(filepath.Join(dir, name), data); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+
+	if err := writeTrace(filepath.Join(dir, TraceFile), t.Trace()); err != nil {
+		return err
+	}
+	absolute, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	config, err := json.MarshalIndent(map[string]string{"data_dir": absolute}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, defaultConfigFile), append(config, '\n'), 0644)
+}
+
+func writeTrace(path string, trace []TraceRequest) error {
+	var lines []string
+	for _, request := range trace {
+		line, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, string(line))
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// Poisson sample by inversion, fine for the small per-minute rates used here
+func poisson(rng *rand.Rand, mean float64) int {
+	limit := math.Exp(-mean)
+	product := rng.Float64()
+	count := 0
+	for product > limit {
+		product *= rng.Float64()
+		count++
+	}
+	return count
+}
+
+// Index in [0, n) with weight 1/(i+1)
+func zipf(rng *rand.Rand, n int) int {
+	var total float64
+	for i := 0; i < n; i++ {
+		total += 1 / float64(i+1)
+	}
+	target := rng.Float64() * total
+	for i := 0; i < n; i++ {
+		target -= 1 / float64(i+1)
+		if target <= 0 {
+			return i
+		}
+	}
+	return n - 1
+}
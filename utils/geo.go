@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"radsched/common"
+	"sort"
+	"strconv"
+)
+
+const earthRadiusKm = 6371.0
+
+// Great-circle distance between two points
+func GreatCircleKm(a common.GeoPoint, b common.GeoPoint) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// RTT expected over a distance from the configured base RTT and per-km cost
+func distanceRTT(km float64) float64 {
+	cfg := GetConfig().GeoIP
+	return cfg.BaseRTTMs + km*cfg.RTTPerKmMs
+}
+
+// Estimated client RTT to every probed region from the client's location
+func EstimateClientRTTs(point common.GeoPoint) map[string]float64 {
+	rtts := make(map[string]float64)
+	for _, region := range GetRegionCatalog().Probed() {
+		if coordinates, exists := regionLocation(region); exists {
+			rtts[region] = distanceRTT(GreatCircleKm(point, coordinates))
+		}
+	}
+	return rtts
+}
+
+// IP networks and their locations, from a MaxMind GeoLite2/GeoIP2 City blocks
+// CSV (network, ..., latitude, longitude, ...)
+type GeoIPDatabase struct {
+	blocks []geoBlock // sorted by first address; networks do not overlap
+}
+
+type geoBlock struct {
+	network *net.IPNet
+	first   net.IP // 16-byte form of the network address
+	point   common.GeoPoint
+}
+
+// Loads the database named by geoip.database in the config, or nil if none is set
+func LoadConfiguredGeoIP() (*GeoIPDatabase, error) {
+	path := GetConfig().GeoIP.Database
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = DataPath(path)
+	}
+	return LoadGeoIPDatabase(path)
+}
+
+func LoadGeoIPDatabase(path string) (*GeoIPDatabase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"network", "latitude", "longitude"} {
+		if _, exists := columns[name]; !exists {
+			return nil, fmt.Errorf("GeoIP database %s has no %s column", path, name)
+		}
+	}
+
+	db := &GeoIPDatabase{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GeoIP database: %v", err)
+		}
+		// networks without a location carry empty coordinates
+		latitude, latErr := strconv.ParseFloat(record[columns["latitude"]], 64)
+		longitude, lonErr := strconv.ParseFloat(record[columns["longitude"]], 64)
+		if latErr != nil || lonErr != nil {
+			continue
+		}
+		_, network, err := net.ParseCIDR(record[columns["network"]])
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %s in GeoIP database: %v", record[columns["network"]], err)
+		}
+		db.blocks = append(db.blocks, geoBlock{
+			network: network,
+			first:   network.IP.To16(),
+			point:   common.GeoPoint{Latitude: latitude, Longitude: longitude},
+		})
+	}
+	sort.Slice(db.blocks, func(i, j int) bool {
+		return bytes.Compare(db.blocks[i].first, db.blocks[j].first) < 0
+	})
+	return db, nil
+}
+
+// Location of the network containing ip
+func (db *GeoIPDatabase) Locate(ip net.IP) (common.GeoPoint, bool) {
+	if db == nil || ip == nil {
+		return common.GeoPoint{}, false
+	}
+	address := ip.To16()
+	// last block starting at or before the address
+	i := sort.Search(len(db.blocks), func(i int) bool {
+		return bytes.Compare(db.blocks[i].first, address) > 0
+	}) - 1
+	if i < 0 || !db.blocks[i].network.Contains(ip) {
+		return common.GeoPoint{}, false
+	}
+	return db.blocks[i].point, true
+}
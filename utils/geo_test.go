@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"radsched/common"
+)
+
+const geoIPFixture = `network,geoname_id,registered_country_geoname_id,latitude,longitude,accuracy_radius
+1.0.0.0/24,1,1,-33.49,143.21,1000
+1.0.1.0/24,2,2,26.06,119.30,50
+1.0.4.0/22,3,3,-37.81,144.96,100
+2.16.0.0/13,4,4,,,
+81.2.69.0/24,5,5,51.51,-0.09,20
+2001:db8::/32,6,6,35.68,139.69,100
+`
+
+func TestGeoIPLocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.csv")
+	if err := os.WriteFile(path, []byte(geoIPFixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := LoadGeoIPDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip    string
+		want  common.GeoPoint
+		found bool
+	}{
+		{"1.0.0.1", common.GeoPoint{Latitude: -33.49, Longitude: 143.21}, true},
+		{"1.0.0.255", common.GeoPoint{Latitude: -33.49, Longitude: 143.21}, true},
+		{"1.0.1.7", common.GeoPoint{Latitude: 26.06, Longitude: 119.30}, true},
+		{"1.0.2.1", common.GeoPoint{}, false}, // between networks
+		{"1.0.7.200", common.GeoPoint{Latitude: -37.81, Longitude: 144.96}, true},
+		{"1.0.8.0", common.GeoPoint{}, false},
+		{"2.16.1.1", common.GeoPoint{}, false}, // network without a location
+		{"81.2.69.160", common.GeoPoint{Latitude: 51.51, Longitude: -0.09}, true},
+		{"0.0.0.1", common.GeoPoint{}, false}, // before the first network
+		{"2001:db8::1", common.GeoPoint{Latitude: 35.68, Longitude: 139.69}, true},
+		{"2001:db9::1", common.GeoPoint{}, false},
+	}
+	for _, test := range tests {
+		got, found := db.Locate(net.ParseIP(test.ip))
+		if found != test.found || got != test.want {
+			t.Errorf("Locate(%s) = %+v, %t; want %+v, %t", test.ip, got, found, test.want, test.found)
+		}
+	}
+
+	var missing *GeoIPDatabase
+	if _, found := missing.Locate(net.ParseIP("1.0.0.1")); found {
+		t.Error("a nil database located an address")
+	}
+	if _, found := db.Locate(nil); found {
+		t.Error("located a nil address")
+	}
+}
+
+func TestLoadGeoIPDatabaseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"missing column", "network,latitude\n1.0.0.0/24,1\n"},
+		{"invalid network", "network,latitude,longitude\nnot-a-network,1,2\n"},
+		{"empty file", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "blocks.csv")
+			if err := os.WriteFile(path, []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadGeoIPDatabase(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"radsched/common"
+	"time"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// Timing of one function invocation
+type InvocationResult struct {
+	Location      string    `json:"location"`
+	ExecutionTime float64   `json:"execution_time"` // ms spent in the function, as reported by it
+	TotalRuntime  float64   `json:"total_runtime"`  // ms from request to response, measured by the caller
+	InvokedAt     time.Time `json:"invoked_at"`
+	ColdStart     *bool     `json:"cold_start,omitempty"` // as reported by the function, nil if unknown
+}
+
+// Runs a function at a location
+type Invoker interface {
+	Invoke(function common.FunctionInfo, location string) (InvocationResult, error)
+	// Sends a lightweight request that only starts or refreshes an instance
+	Warm(function common.FunctionInfo, location string) error
+}
+
+// Invokes the Lambda named after the function in the location's region
+type LambdaInvoker struct{}
+
+func (LambdaInvoker) Invoke(function common.FunctionInfo, location string) (InvocationResult, error) {
+	startTime := time.Now()
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(location))
+	if err != nil {
+		return InvocationResult{}, err
+	}
+	client := lambda.NewFromConfig(cfg)
+
+	input := &lambda.InvokeInput{
+		FunctionName: aws.String(function.FunctionName),
+	}
+	output, err := client.Invoke(context.TODO(), input)
+	if err != nil {
+		return InvocationResult{}, err
+	}
+	if output.FunctionError != nil {
+		return InvocationResult{}, fmt.Errorf("function %s failed in %s: %s", function.FunctionName, location, *output.FunctionError)
+	}
+	totalRuntime := time.Since(startTime).Seconds() * 1000
+
+	// functions report their own execution time in seconds, like SyntheticWorkload
+	result := InvocationResult{
+		Location:      location,
+		ExecutionTime: totalRuntime,
+		TotalRuntime:  totalRuntime,
+		InvokedAt:     startTime.UTC(),
+	}
+	var lambdaResponse struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(output.Payload, &lambdaResponse); err == nil && lambdaResponse.Body != "" {
+		var body struct {
+			ExecutionTime *float64 `json:"execution_time"`
+			ColdStart     *bool    `json:"cold_start"`
+		}
+		if err := json.Unmarshal([]byte(lambdaResponse.Body), &body); err == nil {
+			if body.ExecutionTime != nil {
+				result.ExecutionTime = *body.ExecutionTime * 1000
+			}
+			result.ColdStart = body.ColdStart
+		}
+	}
+
+	return result, nil
+}
+
+// Invokes the function with {"warmup": true}, which handlers should answer without doing work
+func (LambdaInvoker) Warm(function common.FunctionInfo, location string) error {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(location))
+	if err != nil {
+		return err
+	}
+	client := lambda.NewFromConfig(cfg)
+
+	input := &lambda.InvokeInput{
+		FunctionName: aws.String(function.FunctionName),
+		Payload:      []byte(`{"warmup": true}`),
+	}
+	output, err := client.Invoke(context.TODO(), input)
+	if err != nil {
+		return err
+	}
+	if output.FunctionError != nil {
+		return fmt.Errorf("warm-up of %s failed in %s: %s", function.FunctionName, location, *output.FunctionError)
+	}
+	return nil
+}
@@ -1,94 +1,206 @@
 package utils
 
 import (
-	"log"
+	"fmt"
 	"math"
 	"radsched/common"
-	"strconv"
 	"strings"
+	"time"
 	"math/rand"
 )
 
-// experiment locations
-var expLocations = []string{"us-west-1", "us-east-1", "us-east-2"} 
+// Options for a single scheduling decision
+type PolicyOptions struct {
+	Schedule string // exploration schedule; empty uses the configured one
+	Region   string // client region exploration is tracked for; empty tracks the function as a whole
+	Seed     int64  // seed of Rand, recorded with the decision for replay
+	Rand     *rand.Rand
+	Now      time.Time        // time of the decision; zero uses the clock
+	State    *PolicyState     // in-memory state used instead of the data files, e.g. by simulations
+	Network  *NetworkSnapshot // edge RTTs and consistency used instead of the data files, e.g. by simulations
+}
 
-// Choose and return optimal executiuon location based on latency  
-func RunOptLatency(function common.FunctionInfo) common.ExecutionInfo {
-	// get time from datacenter to edges
-	edges, err := GetEdges()
-	if (err != nil) {
-		log.Fatalf("Failed to load edge data: %v", err)
+// Exploration state and instance warmth kept in memory between decisions
+type PolicyState struct {
+	Exploration map[string]FunctionExploration
+	LastInvoked map[string]map[string]time.Time // function -> location -> last invocation
+}
+
+func NewPolicyState() *PolicyState {
+	return &PolicyState{
+		Exploration: make(map[string]FunctionExploration),
+		LastInvoked: make(map[string]map[string]time.Time),
+	}
+}
+
+// Marks the function's instance at a location as used at the given time
+func (state *PolicyState) RecordInvocation(function string, location string, at time.Time) {
+	function = strings.ToLower(function)
+	if state.LastInvoked[function] == nil {
+		state.LastInvoked[function] = make(map[string]time.Time)
+	}
+	state.LastInvoked[function][location] = at
+}
+
+// Builds options whose random source is seeded with seed
+func NewPolicyOptions(schedule string, seed int64) PolicyOptions {
+	return PolicyOptions{
+		Schedule: schedule,
+		Seed:     seed,
+		Rand:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Returns the random source, seeding one from the clock if none was supplied
+func (opts *PolicyOptions) rng() *rand.Rand {
+	if opts.Rand == nil {
+		opts.Seed = time.Now().UnixNano()
+		opts.Rand = rand.New(rand.NewSource(opts.Seed))
+	}
+	return opts.Rand
+}
+
+func (opts *PolicyOptions) now() time.Time {
+	if opts.Now.IsZero() {
+		return time.Now()
+	}
+	return opts.Now
+}
+
+// Next epsilon for the function, from epsilon.json or the in-memory state
+func (opts *PolicyOptions) epsilon(function string) (float64, error) {
+	if opts.State == nil {
+		return GetEpsilon(function, opts.Region, opts.Schedule)
+	}
+	return UpdateEpsilon(opts.State.Exploration, function, opts.Region, opts.Schedule, opts.now())
+}
+
+// Edge to datacenter RTTs, from the network snapshot or edge_datacenter_rtts.json
+func (opts *PolicyOptions) edges() (map[string]map[string]float64, error) {
+	if opts.Network != nil {
+		return opts.Network.Edges, nil
+	}
+	return GetEdges()
+}
+
+// Consistency failure ratio of the function at an edge, from the network
+// snapshot or the recorded statistics
+func (opts *PolicyOptions) consistencyWeight(edge string, function string) (float64, error) {
+	if opts.Network != nil {
+		return opts.Network.consistencyWeight(edge, function), nil
 	}
-	edgeToDatacenters := edges[function.Datacenter]
+	return getConsistencyWeight(edge, function)
+}
 
+// Choose and return optimal executiuon location based on latency  
+func RunOptLatency(function common.FunctionInfo) (common.ExecutionInfo, error) {
 	// get time from client to all nodes 
 	locations, err := GetLocations()
 	if (err != nil) {
-		log.Fatalf("Failed to load edge data: %v", err)
+		return common.ExecutionInfo{}, fmt.Errorf("failed to load edge data: %v", err)
 	}
+	return RunOptLatencyForClient(function, locations, PolicyOptions{})
+}
 
-	executionTime, err := strconv.ParseFloat(strings.Split(function.ExecutionTime, "m")[0], 64)
+// Same as RunOptLatency, for a client with the given RTTs to each location
+func RunOptLatencyForClient(function common.FunctionInfo, locations map[string]float64, opts PolicyOptions) (common.ExecutionInfo, error) {
+	// get time from datacenter to edges
+	edges, err := opts.edges()
 	if (err != nil) {
-		log.Fatalf("Error converting string to float64: %v", err)
+		return common.ExecutionInfo{}, fmt.Errorf("failed to load edge data: %v", err)
+	}
+
+	// fill in unmeasured links from network coordinates
+	rtts := newRTTModel(locations, edges)
+	estimator, err := newLatencyEstimator(function, rtts, opts)
+	if err != nil {
+		return common.ExecutionInfo{}, err
 	}
-	datacenterRuntime := locations[function.Datacenter] + executionTime
+
+	datacenterRuntime := estimator.datacenterRuntime()
+	candidates, explain := FilterCandidates(function, rtts.clientRTTMap())
 	
 	// get optimal node 
 	var optEdge string
 	optEdgeTime := math.MaxFloat64
-	for edge, clientToEdge := range locations {
-		currentEdgeTime := clientToEdge + max(executionTime, edgeToDatacenters[edge])
-		if (currentEdgeTime < optEdgeTime) {
-			optEdgeTime = currentEdgeTime
+	for _, edge := range sortedKeys(candidates) {
+		candidate := estimator.edgeCandidate(edge)
+		markEligible(&candidate, datacenterRuntime)
+		explain = append(explain, candidate)
+		if !candidate.Eligible {
+			continue
+		}
+		if (candidate.Estimate < optEdgeTime) {
+			optEdgeTime = candidate.Estimate
 			optEdge = edge
 		}
 	}
 
-	if (optEdgeTime > datacenterRuntime) {
+	if (optEdge == "") {
 		return common.ExecutionInfo{
 			OptLocation: function.Datacenter,
 			ExecutionTime: datacenterRuntime,
-		}
+			Cost: estimator.cost(function.Datacenter),
+			Propensity: 1,
+			Candidates: explain,
+		}, nil
 	}
 	
 	return common.ExecutionInfo{
 		OptLocation: optEdge,
 		ExecutionTime: optEdgeTime,
-	}
+		Cost: estimator.cost(optEdge),
+		Propensity: 1,
+		Candidates: explain,
+	}, nil
 }
 
 // Choose and return optimal executiuon location based on latency and consistency
-func RunOptWeightedLatency(function common.FunctionInfo) common.ExecutionInfo {
-	// get time from datacenter to edges
-	edges, err := GetEdges()
-	if (err != nil) {
-		log.Fatalf("Failed to load edge data: %v", err)
-	}
-	edgeToDatacenters := edges[function.Datacenter]
-	edgeToDatacentersExp := RemoveLocationsExcept(edgeToDatacenters, expLocations)
-
+func RunOptWeightedLatency(function common.FunctionInfo, opts PolicyOptions) (common.ExecutionInfo, error) {
 	// get time from client to all nodes 
 	locations, err := GetLocations()
 	if (err != nil) {
-		log.Fatalf("Failed to load edge data: %v", err)
+		return common.ExecutionInfo{}, fmt.Errorf("failed to load edge data: %v", err)
 	}
-	locationsExp := RemoveLocationsExcept(locations, expLocations)
+	return RunOptWeightedLatencyForClient(function, locations, opts)
+}
 
-	executionTime, err := strconv.ParseFloat(strings.Split(function.ExecutionTime, "m")[0], 64)
+// Same as RunOptWeightedLatency, for a client with the given RTTs to each location
+func RunOptWeightedLatencyForClient(function common.FunctionInfo, locations map[string]float64, opts PolicyOptions) (common.ExecutionInfo, error) {
+	// get time from datacenter to edges
+	edges, err := opts.edges()
 	if (err != nil) {
-		log.Fatalf("Error converting string to float64: %v", err)
+		return common.ExecutionInfo{}, fmt.Errorf("failed to load edge data: %v", err)
 	}
-	datacenterRuntime := locationsExp[function.Datacenter] + executionTime
+
+	// fill in unmeasured links from network coordinates
+	rtts := newRTTModel(locations, edges)
+	estimator, err := newLatencyEstimator(function, rtts, opts)
+	if err != nil {
+		return common.ExecutionInfo{}, err
+	}
+
+	datacenterRuntime := estimator.datacenterRuntime()
+	candidates, explain := FilterCandidates(function, rtts.clientRTTMap())
 
 	// get eligible nodes
 	eligibleNodes := make([]common.ExecutionInfo, 0); 
-	for edge, clientToEdge := range locationsExp {
-		currentEdgeTime := clientToEdge + max(executionTime, edgeToDatacentersExp[edge])
-		if (currentEdgeTime < datacenterRuntime) {
+	weights := make(map[string]float64)
+	for _, edge := range sortedKeys(candidates) {
+		candidate := estimator.edgeCandidate(edge)
+		markEligible(&candidate, datacenterRuntime)
+		if (candidate.Eligible) {
 			eligibleNodes = append(eligibleNodes, common.ExecutionInfo{
 										OptLocation: edge, 
-										ExecutionTime: currentEdgeTime})
+										ExecutionTime: candidate.Estimate})
+			weighting, err := opts.consistencyWeight(edge, function.FunctionName)
+			if (err != nil) {
+				return common.ExecutionInfo{}, fmt.Errorf("failed to read consistency weight for %s/%s: %v", edge, function.FunctionName, err)
+			}
+			weights[edge] = weighting
+			candidate.Weight = weighting
 		}
+		explain = append(explain, candidate)
 	}
 
 	// if no eligble nodes, run in datacenter
@@ -96,44 +208,125 @@ func RunOptWeightedLatency(function common.FunctionInfo) common.ExecutionInfo {
 		return common.ExecutionInfo{
 			OptLocation: function.Datacenter,
 			ExecutionTime: datacenterRuntime,
-		}
+			Cost: estimator.cost(function.Datacenter),
+			Seed: opts.Seed,
+			Propensity: 1,
+			Candidates: explain,
+		}, nil
 	}
 
 	// With probability epsilon, choose random from eligible nodes, otherwise compute optimal
-	var optEdge string
-	var optEdgeTime float64
-	weightedLatency := math.MaxFloat64
-	epsilon, err := GetEpsilon(function.FunctionName, SMOOTH)
+	epsilon, err := opts.epsilon(function.FunctionName)
 	if err != nil {
-		log.Fatalf("Error calcuating epsilon: %v", err)
-	}
-	isExploreAction := getAction(epsilon)
-	
-	if isExploreAction {
-		randEdge := rand.Intn(len(eligibleNodes))
-		optEdge = eligibleNodes[randEdge].OptLocation
-		optEdgeTime = eligibleNodes[randEdge].ExecutionTime
-	} else {
-		for _, edgeInfo := range eligibleNodes {
-			weighting, err := getConsistencyWeight(edgeInfo.OptLocation, function.FunctionName)
-			if (err != nil) {
-				log.Fatalf("Error converting string to float64: %v", err)
-			}
-			currentWeightedLatency := edgeInfo.ExecutionTime * weighting; 
-			if (currentWeightedLatency < weightedLatency) {
-				weightedLatency = currentWeightedLatency
-				optEdgeTime = edgeInfo.ExecutionTime;
-				optEdge = edgeInfo.OptLocation; 
-			}
-		}
+		return common.ExecutionInfo{}, fmt.Errorf("failed to calculate epsilon: %v", err)
 	}
+	choice, isExploreAction, propensity := chooseEpsilonGreedy(eligibleNodes, weights, epsilon, opts.rng())
+	optEdge, optEdgeTime := choice.OptLocation, choice.ExecutionTime
 
 	return common.ExecutionInfo{
 		OptLocation: optEdge,
 		ExecutionTime: optEdgeTime,
+		Cost: estimator.cost(optEdge),
+		Explored: isExploreAction,
+		Epsilon: epsilon,
+		Propensity: propensity,
+		Seed: opts.Seed,
+		Candidates: explain,
+	}, nil
+}
+
+// Places the function in its primary datacenter, the baseline the other policies are compared to
+func RunDatacenterForClient(function common.FunctionInfo, locations map[string]float64, opts PolicyOptions) (common.ExecutionInfo, error) {
+	edges, err := opts.edges()
+	if (err != nil) {
+		return common.ExecutionInfo{}, fmt.Errorf("failed to load edge data: %v", err)
+	}
+	estimator, err := newLatencyEstimator(function, newRTTModel(locations, edges), opts)
+	if err != nil {
+		return common.ExecutionInfo{}, err
+	}
+	return common.ExecutionInfo{
+		OptLocation: function.Datacenter,
+		ExecutionTime: estimator.datacenterRuntime(),
+		Cost: estimator.cost(function.Datacenter),
+		Propensity: 1,
+	}, nil
+}
+
+// Epsilon-greedy pick among the eligible edges, in location order: with
+// probability epsilon a uniformly random one, otherwise the lowest latency
+// times consistency weight. Returns the pick, whether it explored and the
+// chance the policy had of making it. Live decisions and replays both choose
+// here, so a logged seed and epsilon give the logged choice.
+func chooseEpsilonGreedy(eligibleNodes []common.ExecutionInfo, weights map[string]float64, epsilon float64, rng *rand.Rand) (common.ExecutionInfo, bool, float64) {
+	isExploreAction := getAction(rng, epsilon)
+
+	var choice common.ExecutionInfo
+	weightedLatency := math.MaxFloat64
+	for _, edgeInfo := range eligibleNodes {
+		currentWeightedLatency := edgeInfo.ExecutionTime * weights[edgeInfo.OptLocation]
+		if (currentWeightedLatency < weightedLatency) {
+			weightedLatency = currentWeightedLatency
+			choice = edgeInfo
+		}
+	}
+	greedyEdge := choice.OptLocation
+
+	if isExploreAction {
+		choice = eligibleNodes[rng.Intn(len(eligibleNodes))]
+	}
+
+	// chance of this choice: a uniform exploration pick, or the greedy one
+	propensity := epsilon / float64(len(eligibleNodes))
+	if choice.OptLocation == greedyEdge {
+		propensity += 1 - epsilon
+	}
+	return choice, isExploreAction, propensity
+}
+
+// Repeats a logged decision from its seed, epsilon and candidates alone,
+// without reading or advancing the exploration state or the RTT data. Only
+// decisions whose policy had a source seeded for that decision alone, as in
+// run and serve, replay exactly.
+func ReplayDecision(function common.FunctionInfo, record DecisionRecord) (common.ExecutionInfo, error) {
+	eligible := eligibleCandidates(record)
+	var eligibleNodes []common.ExecutionInfo
+	weights := make(map[string]float64)
+	estimates := make(map[string]float64)
+	for _, candidate := range eligible {
+		eligibleNodes = append(eligibleNodes, common.ExecutionInfo{OptLocation: candidate.Location, ExecutionTime: candidate.Estimate})
+		weights[candidate.Location] = candidate.Weight
+		estimates[candidate.Location] = candidate.Estimate
+	}
+	replay := common.ExecutionInfo{OptLocation: function.Datacenter, Seed: record.Seed, Propensity: 1}
+
+	switch strings.ToLower(record.Policy) {
+	case "datacenter":
+	case "latency":
+		if location := argminCandidate(eligible, func(c DecisionCandidate) float64 { return c.Estimate }); location != "" {
+			replay.OptLocation = location
+		}
+	case "weighted":
+		replay.Epsilon = record.Epsilon
+		if len(eligibleNodes) > 0 {
+			choice, explored, propensity := chooseEpsilonGreedy(eligibleNodes, weights, record.Epsilon, rand.New(rand.NewSource(record.Seed)))
+			replay.OptLocation, replay.Explored, replay.Propensity = choice.OptLocation, explored, propensity
+		}
+	default:
+		return common.ExecutionInfo{}, fmt.Errorf("cannot replay decisions of the %s policy", record.Policy)
+	}
+
+	replay.ExecutionTime = estimates[replay.OptLocation]
+	if replay.OptLocation == function.Datacenter {
+		// the datacenter's own estimate is only logged when it was chosen
+		replay.ExecutionTime = 0
+		if record.Location == function.Datacenter {
+			replay.ExecutionTime = record.Estimate
+		}
 	}
+	return replay, nil
 }
 
-func getAction(epsilon float64) (bool) {
-	return rand.Float64() < epsilon
+func getAction(rng *rand.Rand, epsilon float64) (bool) {
+	return rng.Float64() < epsilon
 }
\ No newline at end of file
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"radsched/common"
+)
+
+// Options of the synthetic data directory the tests run against
+var fixtureOptions = GeneratorOptions{
+	Regions:         4,
+	Clients:         3,
+	Functions:       5,
+	Start:           time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+	Hours:           2,
+	RequestsPerHour: 120,
+	Jitter:          0.1,
+	Diurnal:         0.2,
+	Seed:            1,
+}
+
+// Points the config at a generated data directory before anything reads it
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "radsched-test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create data directory: %v\n", err)
+		os.Exit(1)
+	}
+	// generating reads the config, so it has to point at dir already
+	configPath := filepath.Join(dir, defaultConfigFile)
+	err = os.WriteFile(configPath, []byte(fmt.Sprintf(`{"data_dir": %q}`, dir)), 0644)
+	os.Setenv(configEnvVar, configPath)
+	var topology *SyntheticTopology
+	if err == nil {
+		topology, err = NewSyntheticTopology(fixtureOptions)
+	}
+	if err == nil {
+		err = topology.Write(dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate test data: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Changes the process-wide config for the rest of one test
+func withConfig(t *testing.T, change func(cfg *Config)) {
+	saved := GetConfig()
+	change(&radschedConfig)
+	t.Cleanup(func() { radschedConfig = saved })
+}
+
+// Points the config at a copy of the fixture data directory, for tests that
+// write to it
+func withDataCopy(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS(GetConfig().DataDir)); err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, func(cfg *Config) { cfg.DataDir = dir })
+}
+
+// Adds regions to the process-wide region catalog for the rest of one test
+func withRegions(t *testing.T, regions ...common.Region) {
+	saved := GetRegionCatalog()
+	regionCatalog = append(append(common.RegionCatalog(nil), saved...), regions...)
+	t.Cleanup(func() { regionCatalog = saved })
+}
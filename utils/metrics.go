@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds in ms of the latency histogram buckets
+var latencyBucketsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Counters of a schedule server, served in the Prometheus text format along
+// with consistency, refresh and data age gauges read from the data directory
+// on every scrape
+type Metrics struct {
+	mu           sync.Mutex
+	decisions    map[[2]string]uint64 // policy, location
+	actions      map[string]uint64    // explore or exploit
+	errors       uint64
+	estimated    *histogram
+	observed     *histogram
+	outcomes     uint64
+	inconsistent uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		decisions: make(map[[2]string]uint64),
+		actions:   make(map[string]uint64),
+		estimated: newHistogram(latencyBucketsMs),
+		observed:  newHistogram(latencyBucketsMs),
+	}
+}
+
+// Counts a decision and its estimated latency
+func (m *Metrics) ObserveDecision(policy string, location string, explored bool, estimate float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decisions[[2]string{policy, location}]++
+	action := "exploit"
+	if explored {
+		action = "explore"
+	}
+	m.actions[action]++
+	m.estimated.observe(estimate)
+}
+
+// Counts a reported outcome and its observed latency
+func (m *Metrics) ObserveOutcome(outcome DecisionOutcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outcomes++
+	if outcome.Inconsistent != nil && *outcome.Inconsistent {
+		m.inconsistent++
+	}
+	m.observed.observe(outcome.Latency)
+}
+
+// Counts a schedule request that failed
+func (m *Metrics) ObserveError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors++
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.write(w)
+}
+
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	writeHeader(w, "radsched_decisions_total", "counter", "Scheduling decisions by policy and chosen location.")
+	keys := make([][2]string, 0, len(m.decisions))
+	for key := range m.decisions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "radsched_decisions_total{policy=%s,location=%s} %d\n", quoteLabel(key[0]), quoteLabel(key[1]), m.decisions[key])
+	}
+	writeHeader(w, "radsched_decision_actions_total", "counter", "Weighted decisions that explored or exploited; other policies always exploit.")
+	for _, action := range []string{"explore", "exploit"} {
+		fmt.Fprintf(w, "radsched_decision_actions_total{action=%s} %d\n", quoteLabel(action), m.actions[action])
+	}
+	writeHeader(w, "radsched_schedule_errors_total", "counter", "Schedule requests that failed.")
+	fmt.Fprintf(w, "radsched_schedule_errors_total %d\n", m.errors)
+	m.estimated.write(w, "radsched_estimated_latency_ms", "Estimated latency of chosen locations in ms.")
+	m.observed.write(w, "radsched_observed_latency_ms", "Observed latency reported to /outcome in ms.")
+	writeHeader(w, "radsched_outcomes_total", "counter", "Outcomes reported to /outcome.")
+	fmt.Fprintf(w, "radsched_outcomes_total %d\n", m.outcomes)
+	writeHeader(w, "radsched_outcomes_inconsistent_total", "counter", "Reported outcomes that returned inconsistent state.")
+	fmt.Fprintf(w, "radsched_outcomes_inconsistent_total %d\n", m.inconsistent)
+	m.mu.Unlock()
+
+	writeConsistencyMetrics(w)
+	writeRefreshMetrics(w)
+	writeDataAgeMetrics(w)
+}
+
+// Failure ratio of each edge over all functions, from the edge consistency statistics
+func writeConsistencyMetrics(w io.Writer) {
+	var stats map[string]map[string]FunctionStats
+	if _, err := readDataFile(DataPath(EdgeConsistencyFile), &stats); err != nil {
+		return
+	}
+	writeHeader(w, "radsched_consistency_failure_ratio", "gauge", "Consistency failures over attempts per edge, from the last refresh.")
+	edges := make([]string, 0, len(stats))
+	for edge := range stats {
+		edges = append(edges, edge)
+	}
+	sort.Strings(edges)
+	for _, edge := range edges {
+		attempts, failures := 0, 0
+		for _, functionStats := range stats[edge] {
+			attempts += functionStats.NumAttempts
+			failures += functionStats.NumFailure
+		}
+		if attempts > 0 {
+			fmt.Fprintf(w, "radsched_consistency_failure_ratio{edge=%s} %g\n", quoteLabel(edge), float64(failures)/float64(attempts))
+		}
+	}
+}
+
+// Duration, errors and last success of each bootstrap step
+func writeRefreshMetrics(w io.Writer) {
+	status, err := LoadRefreshStatus()
+	if err != nil || len(status) == 0 {
+		return
+	}
+	steps := make([]string, 0, len(status))
+	for step := range status {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+	writeHeader(w, "radsched_refresh_duration_seconds", "gauge", "Duration of the last run of each bootstrap step.")
+	for _, step := range steps {
+		fmt.Fprintf(w, "radsched_refresh_duration_seconds{step=%s} %g\n", quoteLabel(step), status[step].DurationSeconds)
+	}
+	writeHeader(w, "radsched_refresh_errors_total", "counter", "Failed runs of each bootstrap step.")
+	for _, step := range steps {
+		fmt.Fprintf(w, "radsched_refresh_errors_total{step=%s} %d\n", quoteLabel(step), status[step].Errors)
+	}
+	writeHeader(w, "radsched_refresh_last_success_timestamp_seconds", "gauge", "Unix time of the last successful run of each bootstrap step.")
+	for _, step := range steps {
+		if !status[step].LastSuccess.IsZero() {
+			fmt.Fprintf(w, "radsched_refresh_last_success_timestamp_seconds{step=%s} %d\n", quoteLabel(step), status[step].LastSuccess.Unix())
+		}
+	}
+}
+
+// Time since each data file was last written
+func writeDataAgeMetrics(w io.Writer) {
+	writeHeader(w, "radsched_data_age_seconds", "gauge", "Seconds since each data file in the data directory was written.")
+	now := time.Now()
+	for _, migration := range dataFileMigrations {
+		info, err := os.Stat(DataPath(migration.name))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "radsched_data_age_seconds{dataset=%s} %g\n", quoteLabel(migration.name), now.Sub(info.ModTime()).Seconds())
+	}
+}
+
+// Cumulative histogram in the Prometheus layout
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(value float64) {
+	i := sort.SearchFloat64s(h.bounds, value)
+	h.counts[i]++
+	h.sum += value
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name string, help string) {
+	writeHeader(w, name, "histogram", help)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+func writeHeader(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func quoteLabel(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
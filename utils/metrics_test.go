@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	withDataCopy(t)
+	stats := map[string]map[string]FunctionStats{
+		"eu-west-1": {"f": {NumAttempts: 4, NumFailure: 1}, "g": {NumAttempts: 4, NumFailure: 1}},
+		"us-east-2": {"f": {NumAttempts: 0}},
+	}
+	if err := writeDataFile(DataPath(EdgeConsistencyFile), stats); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordRefresh("edges", time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := NewMetrics()
+	metrics.ObserveDecision("latency", "eu-west-1", false, 30)
+	metrics.ObserveDecision("latency", "eu-west-1", false, 30)
+	metrics.ObserveDecision("weighted", `odd"location`, true, 700)
+	metrics.ObserveError()
+	inconsistent := true
+	metrics.ObserveOutcome(DecisionOutcome{Latency: 45, Inconsistent: &inconsistent})
+
+	server := httptest.NewServer((&ScheduleServer{Metrics: metrics}).Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(body), "\n") {
+		lines[line] = true
+	}
+	for _, want := range []string{
+		"# TYPE radsched_decisions_total counter",
+		`radsched_decisions_total{policy="latency",location="eu-west-1"} 2`,
+		`radsched_decisions_total{policy="weighted",location="odd\"location"} 1`,
+		`radsched_decision_actions_total{action="explore"} 1`,
+		`radsched_decision_actions_total{action="exploit"} 2`,
+		"radsched_schedule_errors_total 1",
+		"# TYPE radsched_estimated_latency_ms histogram",
+		`radsched_estimated_latency_ms_bucket{le="25"} 0`,
+		`radsched_estimated_latency_ms_bucket{le="50"} 2`,
+		`radsched_estimated_latency_ms_bucket{le="500"} 2`,
+		`radsched_estimated_latency_ms_bucket{le="1000"} 3`,
+		`radsched_estimated_latency_ms_bucket{le="+Inf"} 3`,
+		"radsched_estimated_latency_ms_sum 760",
+		"radsched_estimated_latency_ms_count 3",
+		`radsched_observed_latency_ms_bucket{le="50"} 1`,
+		"radsched_outcomes_total 1",
+		"radsched_outcomes_inconsistent_total 1",
+		`radsched_consistency_failure_ratio{edge="eu-west-1"} 0.25`,
+		`radsched_refresh_errors_total{step="edges"} 0`,
+	} {
+		if !lines[want] {
+			t.Errorf("missing %q", want)
+		}
+	}
+	hasPrefix := func(prefix string) bool {
+		for line := range lines {
+			if strings.HasPrefix(line, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, prefix := range []string{
+		`radsched_refresh_duration_seconds{step="edges"} `,
+		`radsched_refresh_last_success_timestamp_seconds{step="edges"} `,
+		`radsched_data_age_seconds{dataset="` + EdgeConsistencyFile + `"} `,
+	} {
+		if !hasPrefix(prefix) {
+			t.Errorf("missing %q", prefix)
+		}
+	}
+	// an edge without attempts has no ratio
+	if hasPrefix(`radsched_consistency_failure_ratio{edge="us-east-2"}`) {
+		t.Error("reported a failure ratio for us-east-2")
+	}
+
+	// without metrics the endpoint is not served
+	bare := httptest.NewServer((&ScheduleServer{}).Handler())
+	defer bare.Close()
+	resp, err = http.Get(bare.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status %d without metrics, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"radsched/common"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Outcome of migrating one data file
+type MigrationResult struct {
+	File        string
+	FromVersion int
+	Backup      string
+	Skipped     string   // reason the file was left alone
+	Invalid     []string // entries that still fail validation and need fixing by hand
+}
+
+// A data file and how to load it into its current shape. upToDate reports
+// whether the payload needs no changes beyond the file version.
+type dataFileMigration struct {
+	name    string
+	upgrade func(path string) (data interface{}, upToDate bool, err error)
+	check   func(data interface{}) []string // optional, describes entries the upgrade could not make valid
+}
+
+var dataFileMigrations = []dataFileMigration{
+	{FunctionRegistryFile, upgradeFunctionRegistry, checkFunctionRegistry},
+	{ClientEdgeRTTFile, upgradeAs[[]common.LocationInfo], nil},
+	{EdgeDatacenterRTTFile, upgradeAs[map[string]map[string]float64], nil},
+	{ClientEdgeBandwidthFile, upgradeAs[map[string]float64], nil},
+	{ClientProfilesFile, upgradeAs[[]ClientProfile], nil},
+	{RegionCatalogFile, upgradeAs[common.RegionCatalog], nil},
+	{FunctionConsistencyFile, upgradeAs[map[string]FunctionStats], nil},
+	{EdgeConsistencyFile, upgradeAs[map[string]map[string]FunctionStats], nil},
+	{EpsilonFile, upgradeEpsilon, nil},
+}
+
+// Upgrades every data file in the data directory to the current version,
+// keeping a backup of each file it rewrites
+func MigrateDataFiles(dryRun bool) ([]MigrationResult, error) {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	var results []MigrationResult
+	for _, migration := range dataFileMigrations {
+		path := DataPath(migration.name)
+		result := MigrationResult{File: path}
+
+		raw, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			result.Skipped = "not found"
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			return results, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		version, _, err := unwrapDataFile(raw)
+		if err != nil {
+			return results, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		result.FromVersion = version
+
+		data, upToDate, err := migration.upgrade(path)
+		if err != nil {
+			return results, err
+		}
+		if migration.check != nil {
+			result.Invalid = migration.check(data)
+		}
+		if version == DataFileVersion && upToDate {
+			result.Skipped = "up to date"
+			results = append(results, result)
+			continue
+		}
+
+		result.Backup = fmt.Sprintf("%s.v%d.%s.bak", path, version, stamp)
+		if !dryRun {
+			if err := os.WriteFile(result.Backup, raw, 0644); err != nil {
+				return results, fmt.Errorf("failed to back up %s: %v", path, err)
+			}
+			if err := writeDataFile(path, data); err != nil {
+				return results, fmt.Errorf("failed to write %s: %v", path, err)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Files whose payload shape has not changed only need the version envelope
+func upgradeAs[T any](path string) (interface{}, bool, error) {
+	var data T
+	if _, err := readDataFile(path, &data); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Registry entries are re-encoded with typed durations and the current schema
+// version. Placeholders older versions of prepare wrote are cleared so the
+// entries pass validation: the "http" function URL sent to Radical and
+// runtimes that are not a single word.
+func upgradeFunctionRegistry(path string) (interface{}, bool, error) {
+	var functions []common.FunctionInfo
+	if _, err := readDataFile(path, &functions); err != nil {
+		return nil, false, err
+	}
+	upToDate := true
+	for i := range functions {
+		function := &functions[i]
+		before := *function
+		function.SchemaVersion = common.FunctionSchemaVersion
+		function.FunctionName = strings.ToLower(strings.TrimSpace(function.FunctionName))
+		function.Datacenter = strings.ToLower(strings.TrimSpace(function.Datacenter))
+		if parsed, err := url.Parse(function.FunctionURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			function.FunctionURL = ""
+		}
+		function.Runtime = strings.TrimSpace(function.Runtime)
+		if strings.ContainsAny(function.Runtime, " \t\n") {
+			function.Runtime = ""
+		}
+		if !reflect.DeepEqual(before, *function) {
+			upToDate = false
+		}
+	}
+	return functions, upToDate, nil
+}
+
+// Registry entries that fail validation after the upgrade
+func checkFunctionRegistry(data interface{}) []string {
+	var invalid []string
+	for _, function := range data.([]common.FunctionInfo) {
+		if err := function.Validate(); err != nil {
+			invalid = append(invalid, fmt.Sprintf("function %q: %v", function.FunctionName, err))
+		}
+	}
+	return invalid
+}
+
+// Legacy function -> epsilon entries become exploration state under lowercased names
+func upgradeEpsilon(path string) (interface{}, bool, error) {
+	rawData := make(map[string]json.RawMessage)
+	if _, err := readDataFile(path, &rawData); err != nil {
+		return nil, false, err
+	}
+	upToDate := true
+	for key, value := range rawData {
+		var legacyEpsilon float64
+		if key != strings.ToLower(key) || json.Unmarshal(value, &legacyEpsilon) == nil {
+			upToDate = false
+		}
+	}
+
+	epsilonData, err := decodeEpsilon(rawData)
+	if err != nil {
+		return nil, false, err
+	}
+	return epsilonData, upToDate, nil
+}
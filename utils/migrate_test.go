@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"radsched/common"
+)
+
+// Rewrites the fixture's data files in the legacy unversioned format, migrates
+// them and checks every payload comes back unchanged in the current version
+func TestMigrateDataFilesRoundTrip(t *testing.T) {
+	epsilonPath := DataPath(EpsilonFile)
+	if err := os.WriteFile(epsilonPath, []byte(`{"function1": 0.5, "Function1": 0.4, "function2": 0.3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(epsilonPath)
+
+	before := make(map[string]json.RawMessage)
+	for _, migration := range dataFileMigrations {
+		raw, err := os.ReadFile(DataPath(migration.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, payload, err := unwrapDataFile(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", migration.name, err)
+		}
+		before[migration.name] = payload
+		if err := os.WriteFile(DataPath(migration.name), payload, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		results, err := MigrateDataFiles(dryRun)
+		if err != nil {
+			t.Fatalf("dry run %t: %v", dryRun, err)
+		}
+		for _, result := range results {
+			if result.Skipped != "" {
+				continue
+			}
+			if result.FromVersion != 1 {
+				t.Errorf("%s migrated from version %d, want 1", result.File, result.FromVersion)
+			}
+			_, statErr := os.Stat(result.Backup)
+			if dryRun != os.IsNotExist(statErr) {
+				t.Errorf("dry run %t: backup %s exists: %t", dryRun, result.Backup, statErr == nil)
+			}
+			if !dryRun {
+				os.Remove(result.Backup)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		data interface{}
+	}{
+		{FunctionRegistryFile, &[]interface{}{}},
+		{ClientEdgeRTTFile, &[]interface{}{}},
+		{EdgeDatacenterRTTFile, &map[string]interface{}{}},
+		{ClientProfilesFile, &[]interface{}{}},
+		{RegionCatalogFile, &[]interface{}{}},
+		{FunctionConsistencyFile, &map[string]interface{}{}},
+		{EdgeConsistencyFile, &map[string]interface{}{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := readDataFile(DataPath(test.name), test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != DataFileVersion {
+				t.Errorf("version %d, want %d", version, DataFileVersion)
+			}
+			want := reflect.New(reflect.TypeOf(test.data).Elem()).Interface()
+			if err := json.Unmarshal(before[test.name], want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.data, want) {
+				t.Errorf("payload changed by the migration:\n got %v\nwant %v", test.data, want)
+			}
+		})
+	}
+
+	epsilonData, err := LoadEpsilon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEpsilon := map[string]float64{"function1": 0.4, "function2": 0.3}
+	if len(epsilonData) != len(wantEpsilon) {
+		t.Errorf("epsilon states %v, want %v", epsilonData, wantEpsilon)
+	}
+	for function, want := range wantEpsilon {
+		if got := epsilonData[function].Epsilon; got != want {
+			t.Errorf("epsilon of %s is %v, want %v", function, got, want)
+		}
+	}
+
+	results, err := MigrateDataFiles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Skipped == "" {
+			t.Errorf("%s migrated twice", result.File)
+		}
+	}
+}
+
+// The epsilon upgrade decodes the file it was given, not the data directory's
+func TestUpgradeEpsilonReadsPath(t *testing.T) {
+	withDataCopy(t)
+	if err := SaveEpsilon(map[string]FunctionExploration{"other": {ExplorationState: ExplorationState{Epsilon: 0.9}}}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), EpsilonFile)
+	if err := os.WriteFile(path, []byte(`{"function1": 0.5, "Function1": 0.4}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	upgraded, upToDate, err := upgradeEpsilon(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]FunctionExploration{"function1": {ExplorationState: ExplorationState{Epsilon: 0.4}}}
+	if upToDate || !reflect.DeepEqual(upgraded, want) {
+		t.Errorf("got %v (up to date %t), want %v", upgraded, upToDate, want)
+	}
+}
+
+// Entries written by the original prepare pass validation once migrated, and
+// those that cannot be repaired are reported
+func TestMigrateLegacyFunctionRegistry(t *testing.T) {
+	withDataCopy(t)
+	legacy := `[
+  {"function_name": "Resize", "execution_time": "100", "function_url": "http", "datacenter": "US-EAST-1"},
+  {"function_name": "thumbnail", "execution_time": "250", "function_url": "", "datacenter": "eu-west-1", "runtime": " python 3.12 "},
+  {"function_name": "render", "execution_time": "1.5s", "function_url": "https://render.example.com/run", "datacenter": "eu-west-1", "runtime": "nodejs20.x"},
+  {"function_name": "broken", "execution_time": "0", "function_url": "http", "datacenter": "us-east-1"}
+]`
+	if err := os.WriteFile(DataPath(FunctionRegistryFile), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := MigrateDataFiles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].File != DataPath(FunctionRegistryFile) || len(results[0].Invalid) != 1 || !strings.Contains(results[0].Invalid[0], `"broken"`) {
+		t.Errorf("registry result %+v, want only broken reported", results[0])
+	}
+
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct{ url, runtime, datacenter string }{
+		"resize":    {"", "", "us-east-1"},
+		"thumbnail": {"", "", "eu-west-1"},
+		"render":    {"https://render.example.com/run", "nodejs20.x", "eu-west-1"},
+	}
+	for _, function := range functions {
+		if function.SchemaVersion != common.FunctionSchemaVersion {
+			t.Errorf("%s has schema version %d", function.FunctionName, function.SchemaVersion)
+		}
+		expected, exists := want[function.FunctionName]
+		if !exists {
+			continue
+		}
+		if err := function.Validate(); err != nil {
+			t.Errorf("%s: %v", function.FunctionName, err)
+		}
+		if got := (struct{ url, runtime, datacenter string }{function.FunctionURL, function.Runtime, function.Datacenter}); got != expected {
+			t.Errorf("%s migrated to %+v, want %+v", function.FunctionName, got, expected)
+		}
+		delete(want, function.FunctionName)
+	}
+	if len(want) > 0 {
+		t.Errorf("missing after the migration: %v", want)
+	}
+}
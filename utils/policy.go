@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fmt"
+	"radsched/common"
+	"sort"
+	"strings"
+)
+
+// Chooses where to run a function for a client with the given RTTs
+type Policy func(function common.FunctionInfo, clientRTTs map[string]float64, opts PolicyOptions) (common.ExecutionInfo, error)
+
+var policies = map[string]Policy{
+	"latency":    RunOptLatencyForClient,
+	"weighted":   RunOptWeightedLatencyForClient,
+	"datacenter": RunDatacenterForClient,
+}
+
+// Makes a policy available to simulate and bench under the given name
+func RegisterPolicy(name string, policy Policy) {
+	policies[strings.ToLower(name)] = policy
+}
+
+func GetPolicy(name string) (Policy, error) {
+	policy, exists := policies[strings.ToLower(name)]
+	if !exists {
+		return nil, fmt.Errorf("unknown policy %s (expected one of %s)", name, strings.Join(PolicyNames(), ", "))
+	}
+	return policy, nil
+}
+
+// Names of the registered policies, sorted
+func PolicyNames() []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
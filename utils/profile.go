@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"radsched/common"
+	"sort"
+	"strings"
+	"time"
+)
+
+const ExecutionProfileFile = "execution_profiles.json"
+
+// Observed execution times of one function at one location
+type ExecutionProfile struct {
+	Samples      []float64 `json:"samples"` // most recent execution times in ms
+	Count        int       `json:"count"`   // invocations observed, including evicted samples
+	LastInvoked  time.Time `json:"last_invoked"`
+	ColdStarts   int       `json:"cold_starts"`
+	WarmOverhead float64   `json:"warm_overhead"` // average total runtime minus execution time of warm invocations
+	LastWarmed   time.Time `json:"last_warmed,omitempty"` // last warm-up sent by the warmer
+	WarmedHits   int       `json:"warmed_hits"`   // warm invocations that followed a warm-up
+	WarmedMisses int       `json:"warmed_misses"` // cold invocations despite a warm-up
+}
+
+// Most recent time an instance at the location was known to be running
+func (profile *ExecutionProfile) LastActive() time.Time {
+	if profile.LastWarmed.After(profile.LastInvoked) {
+		return profile.LastWarmed
+	}
+	return profile.LastInvoked
+}
+
+// location -> profile
+type FunctionProfiles map[string]*ExecutionProfile
+
+// function -> location -> profile
+type ExecutionProfiles map[string]FunctionProfiles
+
+// Fetches learned execution profiles; missing file means nothing learned yet
+func LoadExecutionProfiles() (ExecutionProfiles, error) {
+	profiles := make(ExecutionProfiles)
+	_, err := readDataFile(DataPath(ExecutionProfileFile), &profiles)
+	if os.IsNotExist(err) {
+		return make(ExecutionProfiles), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read execution profiles: %v", err)
+	}
+	return profiles, nil
+}
+
+func SaveExecutionProfiles(profiles ExecutionProfiles) error {
+	if err := writeDataFile(DataPath(ExecutionProfileFile), profiles); err != nil {
+		return fmt.Errorf("failed to write execution profiles: %v", err)
+	}
+	return nil
+}
+
+// Adds an observed invocation to the function's profile at the result's location,
+// and learns the cold start penalty of the function's runtime there. The
+// profiles lock also covers the penalties, which are only written here.
+func RecordExecution(function common.FunctionInfo, result InvocationResult) error {
+	return updateDataFile(ExecutionProfileFile, func() error {
+		profiles, err := LoadExecutionProfiles()
+		if err != nil {
+			return err
+		}
+		penalties, err := LoadColdStartPenalties()
+		if err != nil {
+			return err
+		}
+		profiles.record(function, result, penalties)
+		if err := SaveColdStartPenalties(penalties); err != nil {
+			return err
+		}
+		return SaveExecutionProfiles(profiles)
+	})
+}
+
+func (profiles ExecutionProfiles) record(function common.FunctionInfo, result InvocationResult, penalties ColdStartPenalties) {
+	name := strings.ToLower(function.FunctionName)
+	location := strings.ToLower(result.Location)
+	if profiles[name] == nil {
+		profiles[name] = make(FunctionProfiles)
+	}
+	profile := profiles[name][location]
+	cold := isColdStart(result, profile)
+	if profile == nil {
+		profile = &ExecutionProfile{}
+		profiles[name][location] = profile
+	}
+
+	// invocations following a warm-up measure how well the warmer works
+	if profile.LastWarmed.After(profile.LastInvoked) {
+		if cold {
+			profile.WarmedMisses++
+		} else {
+			profile.WarmedHits++
+		}
+	}
+
+	// the cold start penalty is the overhead beyond what a warm invocation pays
+	overhead := result.TotalRuntime - result.ExecutionTime
+	warmInvocations := profile.Count - profile.ColdStarts
+	if cold {
+		profile.ColdStarts++
+		if warmInvocations > 0 {
+			penalties.learn(function.Runtime, location, max(overhead-profile.WarmOverhead, 0))
+		}
+	} else {
+		profile.WarmOverhead += (overhead - profile.WarmOverhead) / float64(warmInvocations+1)
+	}
+
+	maxSamples := GetConfig().Profiling.MaxSamples
+	profile.Samples = append(profile.Samples, result.ExecutionTime)
+	if maxSamples > 0 && len(profile.Samples) > maxSamples {
+		profile.Samples = profile.Samples[len(profile.Samples)-maxSamples:]
+	}
+	profile.Count++
+	profile.LastInvoked = result.InvokedAt
+}
+
+// Execution time to plan with at a location: the configured quantile of the
+// learned samples once there are enough of them, otherwise the declared time
+func (profiles FunctionProfiles) ExecutionTime(function common.FunctionInfo, location string) float64 {
+	cfg := GetConfig().Profiling
+	profile := profiles[strings.ToLower(location)]
+	if profile == nil || len(profile.Samples) < cfg.MinSamples || len(profile.Samples) == 0 {
+		return function.ExecutionTime.Milliseconds()
+	}
+	return Quantile(profile.Samples, cfg.Quantile)
+}
+
+// Linear-interpolated quantile q in [0, 1] of the values
+func Quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
+
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"radsched/common"
+)
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		q      float64
+		want   float64
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single value", []float64{7}, 0.95, 7},
+		{"minimum", []float64{3, 1, 2}, 0, 1},
+		{"maximum", []float64{3, 1, 2}, 1, 3},
+		{"median of odd count", []float64{5, 1, 3}, 0.5, 3},
+		{"median of even count", []float64{4, 1, 3, 2}, 0.5, 2.5},
+		{"interpolated", []float64{10, 20, 30, 40, 50}, 0.9, 46},
+		{"p99", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0.99, 9.91},
+		{"repeated values", []float64{2, 2, 2, 8}, 0.5, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Quantile(test.values, test.q); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("Quantile(%v, %v) = %v, want %v", test.values, test.q, got, test.want)
+			}
+		})
+	}
+}
+
+func TestQuantileKeepsInput(t *testing.T) {
+	values := []float64{3, 1, 2}
+	Quantile(values, 0.5)
+	if values[0] != 3 || values[1] != 1 || values[2] != 2 {
+		t.Errorf("input reordered to %v", values)
+	}
+}
+
+func TestMean(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{4}, 4},
+		{[]float64{1, 2, 3, 4}, 2.5},
+		{[]float64{-1, 1}, 0},
+		{[]float64{0.1, 0.2, 0.3}, 0.2},
+	}
+	for _, test := range tests {
+		if got := Mean(test.values); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Mean(%v) = %v, want %v", test.values, got, test.want)
+		}
+	}
+}
+
+// Concurrent recorders take the file lock, so none of their samples are lost
+func TestRecordExecutionConcurrent(t *testing.T) {
+	withDataCopy(t)
+	function := common.FunctionInfo{FunctionName: "Concurrent", Datacenter: "us-east-1"}
+	const recorders = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, recorders)
+	for i := 0; i < recorders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- RecordExecution(function, InvocationResult{Location: "eu-west-1", ExecutionTime: float64(10 + i), TotalRuntime: float64(30 + i), InvokedAt: time.Now()})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	profiles, err := LoadExecutionProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile := profiles["concurrent"]["eu-west-1"]; profile == nil || profile.Count != recorders {
+		t.Errorf("profile %+v, want %d invocations", profile, recorders)
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"radsched/common"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// AWS regions: probed with the configured ping script from the client and the
+// PingDatacenters Lambda in each region, invoked as Lambdas
+type AWSProvider struct {
+	LambdaInvoker
+}
+
+// Pings the regions with the ping script, passing each as its name, or as
+// name=endpoint when the catalog gives it an endpoint to ping instead
+func (AWSProvider) ProbeClient(regions []common.Region) (map[string]float64, error) {
+	if len(regions) == 0 {
+		return map[string]float64{}, nil
+	}
+	cfg := GetConfig().AWS
+	args := []string{cfg.PingScript}
+	for _, region := range regions {
+		arg := region.Name
+		if region.Endpoint != "" {
+			arg += "=" + region.Endpoint
+		}
+		args = append(args, arg)
+	}
+	cmd := exec.Command(cfg.Python, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute %s %s: %v", cfg.Python, cfg.PingScript, err)
+	}
+
+	var rttData map[string]float64
+	if err := json.Unmarshal(output, &rttData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RTT data: %v", err)
+	}
+
+	rtts := make(map[string]float64)
+	for _, region := range regions {
+		if rtt, exists := rttData[region.Name]; exists {
+			rtts[region.ID()] = rtt
+		}
+	}
+	return rtts, nil
+}
+
+func (AWSProvider) ProbeFrom(region common.Region, others []common.Region) (map[string]float64, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region.Name))
+	if err != nil {
+		return nil, err
+	}
+	client := lambda.NewFromConfig(cfg)
+
+	input := &lambda.InvokeInput{
+		FunctionName: aws.String("PingDatacenters"),
+	}
+	output, err := client.Invoke(context.TODO(), input)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(output.Payload, &result); err != nil || result.Body == "" {
+		return nil, fmt.Errorf("unexpected body format for region %s", region.Name)
+	}
+	var rttMap map[string]float64
+	if err := json.Unmarshal([]byte(result.Body), &rttMap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RTT data for region %s: %v", region.Name, err)
+	}
+	return rttMap, nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"radsched/common"
+)
+
+// The ping script is given the catalog's regions, so a region added to the
+// catalog is probed
+func TestAWSProbeClientPassesRegions(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	// answers 10 ms for a region pinged at its endpoint and 20 ms otherwise
+	script := filepath.Join(t.TempDir(), "ping.py")
+	err = os.WriteFile(script, []byte(`import json, sys
+rtts = {}
+for arg in sys.argv[1:]:
+    name, _, endpoint = arg.partition("=")
+    rtts[name] = 10 if endpoint else 20
+print(json.dumps(rtts))
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, func(cfg *Config) {
+		cfg.AWS.Python, cfg.AWS.PingScript = python, script
+	})
+
+	tests := []struct {
+		name    string
+		regions []common.Region
+		want    map[string]float64
+	}{
+		{"none", nil, map[string]float64{}},
+		{
+			"catalog regions",
+			[]common.Region{{Name: "eu-west-1", Provider: "aws"}, {Name: "me-central-1", Provider: "aws"}},
+			map[string]float64{"eu-west-1": 20, "me-central-1": 20},
+		},
+		{
+			"endpoint",
+			[]common.Region{{Name: "us-east-1", Provider: "aws", Endpoint: "https://ping.example.com"}},
+			map[string]float64{"us-east-1": 10},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := AWSProvider{}.ProbeClient(test.regions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"radsched/common"
+	"strings"
+	"time"
+)
+
+const (
+	httpProviderTimeout = 30 * time.Second
+	httpProbeAttempts   = 3
+)
+
+// Generic HTTP locations such as on-prem nodes or other FaaS platforms. A
+// location's catalog endpoint must serve:
+//
+//	GET  /ping                   any 2xx response, timed by the client
+//	GET  /rtts?targets=a,b       {"a": ms, "b": ms} measured from the location
+//	POST /invoke/<function>      {"execution_time": seconds, "cold_start": bool}
+//
+// Warm-ups are invocations with the body {"warmup": true}.
+type HTTPProvider struct{}
+
+func (HTTPProvider) client() *http.Client {
+	return &http.Client{Timeout: httpProviderTimeout}
+}
+
+// Fastest of a few round trips to each endpoint's /ping
+func (p HTTPProvider) ProbeClient(regions []common.Region) (map[string]float64, error) {
+	client := p.client()
+	rtts := make(map[string]float64)
+	for _, region := range regions {
+		fastest := math.MaxFloat64
+		for attempt := 0; attempt < httpProbeAttempts; attempt++ {
+			startTime := time.Now()
+			resp, err := client.Get(endpointURL(region, "ping"))
+			if err != nil {
+				continue
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode/100 == 2 {
+				fastest = math.Min(fastest, time.Since(startTime).Seconds()*1000)
+			}
+		}
+		if fastest < math.MaxFloat64 {
+			rtts[region.ID()] = fastest
+		}
+	}
+	return rtts, nil
+}
+
+func (p HTTPProvider) ProbeFrom(region common.Region, others []common.Region) (map[string]float64, error) {
+	targets := make([]string, 0, len(others))
+	for _, other := range others {
+		targets = append(targets, other.ID())
+	}
+	resp, err := p.client().Get(endpointURL(region, "rtts") + "?targets=" + url.QueryEscape(strings.Join(targets, ",")))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var rtts map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&rtts); err != nil {
+		return nil, fmt.Errorf("failed to parse RTTs from %s: %v", region.ID(), err)
+	}
+	return rtts, nil
+}
+
+func (p HTTPProvider) Invoke(function common.FunctionInfo, location string) (InvocationResult, error) {
+	startTime := time.Now()
+	body, err := p.post(function, location, []byte(`{}`))
+	if err != nil {
+		return InvocationResult{}, err
+	}
+	totalRuntime := time.Since(startTime).Seconds() * 1000
+
+	result := InvocationResult{
+		Location:      location,
+		ExecutionTime: totalRuntime,
+		TotalRuntime:  totalRuntime,
+		InvokedAt:     startTime.UTC(),
+	}
+	var reported struct {
+		ExecutionTime *float64 `json:"execution_time"`
+		ColdStart     *bool    `json:"cold_start"`
+	}
+	if err := json.Unmarshal(body, &reported); err == nil {
+		if reported.ExecutionTime != nil {
+			result.ExecutionTime = *reported.ExecutionTime * 1000
+		}
+		result.ColdStart = reported.ColdStart
+	}
+	return result, nil
+}
+
+func (p HTTPProvider) Warm(function common.FunctionInfo, location string) error {
+	_, err := p.post(function, location, []byte(`{"warmup": true}`))
+	return err
+}
+
+func (p HTTPProvider) post(function common.FunctionInfo, location string, payload []byte) ([]byte, error) {
+	region, known := GetRegionCatalog().Get(location)
+	if !known {
+		return nil, fmt.Errorf("location %s is not in the region catalog", location)
+	}
+	resp, err := p.client().Post(endpointURL(region, "invoke/"+url.PathEscape(function.FunctionName)), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("function %s failed at %s: status %d", function.FunctionName, location, resp.StatusCode)
+	}
+	return body, nil
+}
+
+func endpointURL(region common.Region, path string) string {
+	return strings.TrimRight(region.Endpoint, "/") + "/" + path
+}
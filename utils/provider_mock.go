@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"radsched/common"
+	"strconv"
+	"time"
+)
+
+// RTT the mock provider reports when a region gives it nothing better
+const mockDefaultRTTMs = 50
+
+// Offline provider for tests and demos. It reports the client RTT from the
+// region's "client_rtt_ms" option, RTTs between regions from their distance,
+// and invocations that take exactly the declared execution time plus the
+// client RTT, which falls back to the bootstrap client RTT when the option is unset.
+type MockProvider struct{}
+
+func (MockProvider) ProbeClient(regions []common.Region) (map[string]float64, error) {
+	rtts := make(map[string]float64)
+	for _, region := range regions {
+		rtts[region.ID()] = mockClientRTT(region)
+	}
+	return rtts, nil
+}
+
+func (MockProvider) ProbeFrom(region common.Region, others []common.Region) (map[string]float64, error) {
+	rtts := make(map[string]float64)
+	for _, other := range others {
+		rtt := float64(mockDefaultRTTMs)
+		if region.Location != nil && other.Location != nil {
+			rtt = distanceRTT(GreatCircleKm(*region.Location, *other.Location))
+		}
+		rtts[other.ID()] = rtt
+	}
+	return rtts, nil
+}
+
+func (MockProvider) Invoke(function common.FunctionInfo, location string) (InvocationResult, error) {
+	clientRTT := float64(mockDefaultRTTMs)
+	region, known := GetRegionCatalog().Get(location)
+	if known {
+		clientRTT = mockClientRTT(region)
+	}
+	if !known || region.Options["client_rtt_ms"] == "" {
+		if locations, err := GetLocations(); err == nil {
+			if rtt, measured := locations[location]; measured && !IsUnreachable(rtt) {
+				clientRTT = rtt
+			}
+		}
+	}
+	executionTime := function.ExecutionTime.Milliseconds()
+	coldStart := false
+	return InvocationResult{
+		Location:      location,
+		ExecutionTime: executionTime,
+		TotalRuntime:  clientRTT + executionTime,
+		InvokedAt:     time.Now().UTC(),
+		ColdStart:     &coldStart,
+	}, nil
+}
+
+func (MockProvider) Warm(function common.FunctionInfo, location string) error {
+	return nil
+}
+
+func mockClientRTT(region common.Region) float64 {
+	if rtt, err := strconv.ParseFloat(region.Options["client_rtt_ms"], 64); err == nil {
+		return rtt
+	}
+	return mockDefaultRTTMs
+}
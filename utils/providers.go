@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"radsched/common"
+	"sort"
+)
+
+// Probes and runs functions at the locations of one provider. Locations are
+// identified by their catalog ID, the provider plus its own location name.
+type Provider interface {
+	Invoker
+	// RTT in ms from this machine to each of the regions; regions that do not answer are left out
+	ProbeClient(regions []common.Region) (map[string]float64, error)
+	// RTT in ms from a region to each of the others, as measured at that region
+	ProbeFrom(region common.Region, others []common.Region) (map[string]float64, error)
+}
+
+var providers = map[string]Provider{
+	"aws":  AWSProvider{},
+	"http": HTTPProvider{},
+	"mock": MockProvider{},
+}
+
+// Makes a provider available to region catalog entries with the given provider name
+func RegisterProvider(name string, provider Provider) {
+	providers[name] = provider
+}
+
+func GetProvider(name string) (Provider, error) {
+	provider, exists := providers[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown provider %s", name)
+	}
+	return provider, nil
+}
+
+// Probed catalog regions grouped by provider
+func probedRegionsByProvider() map[string][]common.Region {
+	catalog := GetRegionCatalog()
+	byProvider := make(map[string][]common.Region)
+	for _, id := range catalog.Probed() {
+		region, _ := catalog.Get(id)
+		byProvider[region.Provider] = append(byProvider[region.Provider], region)
+	}
+	return byProvider
+}
+
+// Invokes and warms each location through the provider the region catalog lists for it
+type ProviderInvoker struct{}
+
+func (ProviderInvoker) providerFor(location string) (Provider, error) {
+	region, known := GetRegionCatalog().Get(location)
+	if !known {
+		return nil, fmt.Errorf("location %s is not in the region catalog", location)
+	}
+	return GetProvider(region.Provider)
+}
+
+func (invoker ProviderInvoker) Invoke(function common.FunctionInfo, location string) (InvocationResult, error) {
+	provider, err := invoker.providerFor(location)
+	if err != nil {
+		return InvocationResult{}, err
+	}
+	return provider.Invoke(function, location)
+}
+
+func (invoker ProviderInvoker) Warm(function common.FunctionInfo, location string) error {
+	provider, err := invoker.providerFor(location)
+	if err != nil {
+		return err
+	}
+	return provider.Warm(function, location)
+}
+
+// Names of the registered providers, sorted
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"radsched/common"
+)
+
+// An HTTP location as documented on HTTPProvider, recording the bodies it was invoked with
+type fakeHTTPLocation struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (l *fakeHTTPLocation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/ping":
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/rtts":
+		rtts := make(map[string]float64)
+		for i, target := range strings.Split(r.URL.Query().Get("targets"), ",") {
+			rtts[target] = float64(10 * (i + 1))
+		}
+		json.NewEncoder(w).Encode(rtts)
+	case r.Method == http.MethodPost && r.URL.Path == "/invoke/resize":
+		body, _ := io.ReadAll(r.Body)
+		l.mu.Lock()
+		l.bodies = append(l.bodies, string(body))
+		l.mu.Unlock()
+		w.Write([]byte(`{"execution_time": 0.25, "cold_start": true}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	location := &fakeHTTPLocation{}
+	up := httptest.NewServer(location)
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	upRegion := common.Region{Name: "up", Provider: "http", Endpoint: up.URL + "/"}
+	downRegion := common.Region{Name: "down", Provider: "http", Endpoint: down.URL}
+	withRegions(t, upRegion, downRegion)
+	provider := HTTPProvider{}
+
+	rtts, err := provider.ProbeClient([]common.Region{upRegion, downRegion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, answered := rtts["http:down"]; answered || rtts["http:up"] <= 0 || len(rtts) != 1 {
+		t.Errorf("client RTTs %v, want one for http:up only", rtts)
+	}
+
+	others := []common.Region{downRegion, {Name: "eu-west-1", Provider: "aws"}}
+	rtts, err = provider.ProbeFrom(upRegion, others)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{"http:down": 10, "eu-west-1": 20}; !reflect.DeepEqual(rtts, want) {
+		t.Errorf("RTTs from http:up %v, want %v", rtts, want)
+	}
+	if _, err := provider.ProbeFrom(downRegion, nil); err == nil {
+		t.Error("expected an error probing from a failing location")
+	}
+
+	// invocations are routed by the catalog to the provider of the location
+	function := common.FunctionInfo{FunctionName: "resize"}
+	result, err := ProviderInvoker{}.Invoke(function, "http:up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExecutionTime != 250 || result.ColdStart == nil || !*result.ColdStart || result.TotalRuntime <= 0 {
+		t.Errorf("got %+v, want the reported 250 ms cold start", result)
+	}
+	if err := (ProviderInvoker{}).Warm(function, "http:up"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`{}`, `{"warmup": true}`}; !reflect.DeepEqual(location.bodies, want) {
+		t.Errorf("invoked with %q, want %q", location.bodies, want)
+	}
+
+	if _, err := (ProviderInvoker{}).Invoke(function, "http:down"); err == nil {
+		t.Error("expected an error from a failing location")
+	}
+	if _, err := (ProviderInvoker{}).Invoke(function, "http:nowhere"); err == nil {
+		t.Error("expected an error for a location outside the catalog")
+	}
+}
+
+func TestMockProvider(t *testing.T) {
+	london := common.GeoPoint{Latitude: 51.51, Longitude: -0.13}
+	paris := common.GeoPoint{Latitude: 48.86, Longitude: 2.35}
+	near := common.Region{Name: "near", Provider: "mock", Location: &london, Options: map[string]string{"client_rtt_ms": "12"}}
+	far := common.Region{Name: "far", Provider: "mock", Location: &paris}
+	unplaced := common.Region{Name: "unplaced", Provider: "mock"}
+	withRegions(t, near, far, unplaced)
+	provider := MockProvider{}
+
+	rtts, err := provider.ProbeClient([]common.Region{near, far})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{"mock:near": 12, "mock:far": mockDefaultRTTMs}; !reflect.DeepEqual(rtts, want) {
+		t.Errorf("client RTTs %v, want %v", rtts, want)
+	}
+
+	rtts, err = provider.ProbeFrom(near, []common.Region{far, unplaced})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"mock:far": distanceRTT(GreatCircleKm(london, paris)), "mock:unplaced": mockDefaultRTTMs}
+	if !reflect.DeepEqual(rtts, want) {
+		t.Errorf("RTTs from mock:near %v, want %v", rtts, want)
+	}
+
+	function := common.FunctionInfo{FunctionName: "resize", ExecutionTime: common.Duration(80 * time.Millisecond)}
+	result, err := ProviderInvoker{}.Invoke(function, "mock:near")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExecutionTime != 80 || result.TotalRuntime != 92 || result.ColdStart == nil || *result.ColdStart {
+		t.Errorf("got %+v, want 80 ms warm plus the 12 ms client RTT", result)
+	}
+
+	// without a client_rtt_ms option a measured bootstrap RTT is used, but not an unreachable one
+	withDataCopy(t)
+	probes := []common.LocationInfo{
+		{LocationName: "mock:far", RoundTripTime: "30 ms"},
+		{LocationName: "mock:unplaced", RoundTripTime: "-1"},
+	}
+	if err := writeDataFile(DataPath(ClientEdgeRTTFile), probes); err != nil {
+		t.Fatal(err)
+	}
+	for location, want := range map[string]float64{"mock:far": 110, "mock:unplaced": 80 + mockDefaultRTTMs} {
+		result, err := ProviderInvoker{}.Invoke(function, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.TotalRuntime != want {
+			t.Errorf("%s: total runtime %v, want %v", location, result.TotalRuntime, want)
+		}
+	}
+}
+
+func TestGetProvider(t *testing.T) {
+	for _, name := range []string{"aws", "http", "mock"} {
+		if _, err := GetProvider(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := GetProvider("gcp"); err == nil {
+		t.Error("expected an error for an unregistered provider")
+	}
+
+	RegisterProvider("test", MockProvider{})
+	defer delete(providers, "test")
+	if provider, err := GetProvider("test"); err != nil || provider != (MockProvider{}) {
+		t.Errorf("registered provider: got %v, %v", provider, err)
+	}
+	if names := ProviderNames(); !reflect.DeepEqual(names, []string{"aws", "http", "mock", "test"}) {
+		t.Errorf("provider names %v", names)
+	}
+}
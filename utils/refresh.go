@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const RefreshStatusFile = "refresh_status.json"
+
+// Latest run of one bootstrap step, exposed by serve's /metrics
+type RefreshStatus struct {
+	LastRun         time.Time `json:"last_run"`
+	LastSuccess     time.Time `json:"last_success"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	LastError       string    `json:"last_error,omitempty"`
+	Runs            int       `json:"runs"`
+	Errors          int       `json:"errors"`
+}
+
+var refreshStatusMu sync.Mutex
+
+func LoadRefreshStatus() (map[string]RefreshStatus, error) {
+	status := make(map[string]RefreshStatus)
+	if _, err := readDataFile(DataPath(RefreshStatusFile), &status); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Records how long a bootstrap step that started at started took and whether it failed
+func RecordRefresh(step string, started time.Time, stepErr error) error {
+	refreshStatusMu.Lock()
+	defer refreshStatusMu.Unlock()
+
+	status, err := LoadRefreshStatus()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	entry := status[step]
+	entry.LastRun = now
+	entry.DurationSeconds = now.Sub(started).Seconds()
+	entry.Runs++
+	if stepErr != nil {
+		entry.LastError = stepErr.Error()
+		entry.Errors++
+	} else {
+		entry.LastError = ""
+		entry.LastSuccess = now
+	}
+	status[step] = entry
+	return writeDataFile(DataPath(RefreshStatusFile), status)
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// A failed run is counted and keeps the last success; the next success clears the error
+func TestRecordRefresh(t *testing.T) {
+	withDataCopy(t)
+	started := time.Now().Add(-2 * time.Second)
+	runs := []struct {
+		step string
+		err  error
+	}{
+		{"edges", nil},
+		{"edges", errors.New("PingDatacenters timed out")},
+		{"client", nil},
+	}
+	var firstSuccess time.Time
+	for i, run := range runs {
+		if err := RecordRefresh(run.step, started, run.err); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			status, err := LoadRefreshStatus()
+			if err != nil {
+				t.Fatal(err)
+			}
+			firstSuccess = status["edges"].LastSuccess
+		}
+	}
+
+	status, err := LoadRefreshStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := status["edges"]
+	if edges.Runs != 2 || edges.Errors != 1 || edges.LastError != "PingDatacenters timed out" {
+		t.Errorf("edges %+v, want 2 runs and the last one failed", edges)
+	}
+	if firstSuccess.IsZero() || !edges.LastSuccess.Equal(firstSuccess) || !edges.LastRun.After(edges.LastSuccess) {
+		t.Errorf("edges %+v, want the last success from the first run", edges)
+	}
+	if edges.DurationSeconds < 2 {
+		t.Errorf("edges took %v s, want at least 2", edges.DurationSeconds)
+	}
+	if client := status["client"]; client.Runs != 1 || client.Errors != 0 || client.LastSuccess.IsZero() {
+		t.Errorf("client %+v, want one successful run", client)
+	}
+
+	if err := RecordRefresh("edges", time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+	status, err = LoadRefreshStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edges := status["edges"]; edges.LastError != "" || edges.Errors != 1 || !edges.LastSuccess.Equal(edges.LastRun) {
+		t.Errorf("edges %+v, want the error cleared and the count kept", edges)
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"radsched/common"
+	"strings"
+	"sync"
+)
+
+const RegionCatalogFile = "regions.json"
+
+var (
+	regionCatalog     common.RegionCatalog
+	regionCatalogOnce sync.Once
+)
+
+// Loads the region catalog from the data directory, or the built-in catalog if there is none
+func LoadRegionCatalog() (common.RegionCatalog, error) {
+	var catalog common.RegionCatalog
+	_, err := readDataFile(DataPath(RegionCatalogFile), &catalog)
+	if os.IsNotExist(err) {
+		return common.DefaultRegionCatalog(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read region catalog: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range catalog {
+		region := &catalog[i]
+		region.Name = strings.ToLower(strings.TrimSpace(region.Name))
+		region.Provider = strings.ToLower(region.Provider)
+		region.Jurisdiction = strings.ToLower(region.Jurisdiction)
+		if region.Name == "" {
+			return nil, fmt.Errorf("region catalog entry %d has no name", i)
+		}
+		if region.Provider == "" {
+			region.Provider = "aws"
+		}
+		if _, err := GetProvider(region.Provider); err != nil {
+			return nil, fmt.Errorf("region %s: %v", region.Name, err)
+		}
+		if region.Provider == "http" && region.Endpoint == "" {
+			return nil, fmt.Errorf("region %s: http locations need an endpoint", region.ID())
+		}
+		if seen[region.ID()] {
+			return nil, fmt.Errorf("region %s is listed twice in the region catalog", region.ID())
+		}
+		seen[region.ID()] = true
+	}
+	return catalog, nil
+}
+
+func SaveRegionCatalog(catalog common.RegionCatalog) error {
+	return writeDataFile(DataPath(RegionCatalogFile), catalog)
+}
+
+// Returns the process-wide region catalog, loading it on first use
+func GetRegionCatalog() common.RegionCatalog {
+	regionCatalogOnce.Do(func() {
+		catalog, err := LoadRegionCatalog()
+		if err != nil {
+			log.Fatalf("Failed to load region catalog: %v", err)
+		}
+		regionCatalog = catalog
+	})
+	return regionCatalog
+}
+
+// Tags of a region from the catalog and from region_tags in the config
+func regionTags(location string) []string {
+	tags := append([]string(nil), GetConfig().RegionTags[location]...)
+	if region, exists := GetRegionCatalog().Get(location); exists {
+		tags = append(tags, region.Tags...)
+	}
+	return tags
+}
+
+// Coordinates of a region, if the catalog has them
+func regionLocation(name string) (common.GeoPoint, bool) {
+	region, exists := GetRegionCatalog().Get(name)
+	if !exists || region.Location == nil {
+		return common.GeoPoint{}, false
+	}
+	return *region.Location, true
+}
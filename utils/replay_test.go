@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// The same seed and exploration state give the same choice, and the logged
+// decision replays to it without the state
+func TestReplayDecision(t *testing.T) {
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientRTTs, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := fixtureOptions.Start.Add(time.Hour)
+	tests := []struct {
+		name   string
+		policy string
+		run    Policy
+	}{
+		{"weighted", "weighted", RunOptWeightedLatencyForClient},
+		{"latency", "latency", RunOptLatencyForClient},
+		{"datacenter", "datacenter", RunDatacenterForClient},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			explored := 0
+			for _, function := range functions {
+				for seed := int64(1); seed <= 20; seed++ {
+					decide := func() DecisionRecord {
+						opts := NewPolicyOptions(INVERSE_N, seed)
+						opts.Now, opts.State = now, NewPolicyState()
+						decision, err := test.run(function, clientRTTs, opts)
+						if err != nil {
+							t.Fatal(err)
+						}
+						return NewDecisionRecord(now, function.FunctionName, "", test.policy, decision)
+					}
+					first, second := decide(), decide()
+					if first.Location != second.Location || first.Explored != second.Explored || first.Epsilon != second.Epsilon {
+						t.Fatalf("%s with seed %d chose %s (explored %t, epsilon %v), then %s (explored %t, epsilon %v)", function.FunctionName, seed,
+							first.Location, first.Explored, first.Epsilon, second.Location, second.Explored, second.Epsilon)
+					}
+
+					// replay from the record as it is stored in the log
+					line, err := json.Marshal(first)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var logged DecisionRecord
+					if err := json.Unmarshal(line, &logged); err != nil {
+						t.Fatal(err)
+					}
+					replay, err := ReplayDecision(function, logged)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if replay.OptLocation != first.Location || replay.Explored != first.Explored || replay.Propensity != first.Propensity {
+						t.Errorf("%s with seed %d: replay chose %s (explored %t, propensity %v), logged %s (explored %t, propensity %v)", function.FunctionName, seed,
+							replay.OptLocation, replay.Explored, replay.Propensity, first.Location, first.Explored, first.Propensity)
+					}
+					if first.Explored {
+						explored++
+					}
+				}
+			}
+			if test.policy == "weighted" && explored == 0 {
+				t.Error("no decision explored, so replaying exploration went untested")
+			}
+		})
+	}
+
+	if _, err := ReplayDecision(functions[0], DecisionRecord{Policy: "thompson"}); err == nil {
+		t.Error("replaying an unknown policy succeeded")
+	}
+}
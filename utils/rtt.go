@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RTT recorded for a link that was probed but did not answer
+const UnreachableRTT = -1.0
+
+// How much is known about a link
+type RTTStatus string
+
+const (
+	RTTMeasured    RTTStatus = "measured"
+	RTTImputed     RTTStatus = "imputed"     // not measured, estimated from network coordinates
+	RTTUnreachable RTTStatus = "unreachable" // probed without an answer
+	RTTUnknown     RTTStatus = "unknown"     // neither measured nor estimable
+)
+
+// Unreachable links are stored as a negative RTT
+func IsUnreachable(rtt float64) bool {
+	return rtt < 0
+}
+
+// Round trip time of a link, measured or predicted from network coordinates.
+// Unreachable links carry their coordinate estimate, if there is one.
+type RTTEstimate struct {
+	RTT         float64
+	Uncertainty float64 // ms; 0 for measured links
+	Status      RTTStatus
+}
+
+// The less certain of two statuses
+func worseStatus(a RTTStatus, b RTTStatus) RTTStatus {
+	rank := map[RTTStatus]int{RTTMeasured: 0, RTTImputed: 1, RTTUnreachable: 2, RTTUnknown: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// Measured client and edge RTTs, with coordinate estimates for the links that
+// were not measured
+type rttModel struct {
+	clientRTTs  map[string]float64
+	edges       map[string]map[string]float64
+	coordinates NetworkCoordinates
+}
+
+// Coordinates fitted to the last edge RTTs seen, so the fit only reruns when
+// the edge dataset changes rather than on every decision
+var edgeCoordinateCache struct {
+	sync.Mutex
+	fingerprint uint64
+	coordinates NetworkCoordinates
+}
+
+// Fits the regions to the edge RTTs once per dataset, then places the client
+// against them
+func newRTTModel(locations map[string]float64, edges map[string]map[string]float64) *rttModel {
+	var samples []rttSample
+	for _, location := range sortedKeys(locations) {
+		if locations[location] > 0 {
+			samples = append(samples, rttSample{from: ClientNode, to: location, rtt: locations[location]})
+		}
+	}
+	return &rttModel{
+		clientRTTs:  locations,
+		edges:       edges,
+		coordinates: edgeCoordinates(edges).withNode(ClientNode, samples),
+	}
+}
+
+// Fitted coordinates of the regions, reused while the edge RTTs are unchanged
+func edgeCoordinates(edges map[string]map[string]float64) NetworkCoordinates {
+	var samples []rttSample
+	hash := fnv.New64a()
+	for _, datacenter := range sortedEdgeKeys(edges) {
+		for _, edge := range sortedKeys(edges[datacenter]) {
+			if edge != datacenter && edges[datacenter][edge] > 0 {
+				samples = append(samples, rttSample{from: datacenter, to: edge, rtt: edges[datacenter][edge]})
+				fmt.Fprintf(hash, "%s\x00%s\x00%x\x00", datacenter, edge, math.Float64bits(edges[datacenter][edge]))
+			}
+		}
+	}
+	fingerprint := hash.Sum64()
+
+	edgeCoordinateCache.Lock()
+	defer edgeCoordinateCache.Unlock()
+	if edgeCoordinateCache.coordinates == nil || edgeCoordinateCache.fingerprint != fingerprint {
+		edgeCoordinateCache.coordinates = FitCoordinates(samples)
+		edgeCoordinateCache.fingerprint = fingerprint
+	}
+	return edgeCoordinateCache.coordinates
+}
+
+// RTT from the client to a location
+func (m *rttModel) clientRTT(location string) RTTEstimate {
+	rtt, exists := m.clientRTTs[location]
+	return m.lookup(ClientNode, location, rtt, exists)
+}
+
+// RTT between an edge and a datacenter, measured in either direction
+func (m *rttModel) edgeRTT(datacenter string, edge string) RTTEstimate {
+	rtt, exists := m.edges[datacenter][edge]
+	if !exists {
+		rtt, exists = m.edges[edge][datacenter]
+	}
+	return m.lookup(datacenter, edge, rtt, exists)
+}
+
+func (m *rttModel) lookup(from string, to string, rtt float64, measured bool) RTTEstimate {
+	if measured && !IsUnreachable(rtt) {
+		return RTTEstimate{RTT: rtt, Status: RTTMeasured}
+	}
+	estimate := RTTEstimate{RTT: UnreachableRTT, Status: RTTUnknown}
+	if imputed, uncertainty, ok := m.coordinates.Estimate(from, to); ok {
+		estimate = RTTEstimate{RTT: imputed, Uncertainty: uncertainty, Status: RTTImputed}
+	} else if km, ok := regionDistance(from, to); ok {
+		// nothing measured at either end, fall back to distance
+		imputed := distanceRTT(km)
+		estimate = RTTEstimate{RTT: imputed, Uncertainty: imputed / 2, Status: RTTImputed}
+	}
+	if measured {
+		estimate.Status = RTTUnreachable
+	}
+	return estimate
+}
+
+// Client RTT to every location that was probed or can be estimated
+func (m *rttModel) clientRTTMap() map[string]float64 {
+	locations := make(map[string]float64)
+	for location := range m.coordinates {
+		if location != ClientNode {
+			locations[location] = m.clientRTT(location).RTT
+		}
+	}
+	for location := range m.clientRTTs {
+		locations[location] = m.clientRTT(location).RTT
+	}
+	return locations
+}
+
+// Describes the links between the client and the regions, and between the
+// regions, that are unreachable or were never measured
+func MeasurementGaps(locations map[string]float64, edges map[string]map[string]float64) []string {
+	var gaps []string
+	report := func(from string, unreachable []string, missing []string) {
+		if len(unreachable) > 0 {
+			gaps = append(gaps, fmt.Sprintf("%s -> %s: unreachable", from, strings.Join(unreachable, ", ")))
+		}
+		if len(missing) > 0 {
+			gaps = append(gaps, fmt.Sprintf("%s -> %s: not measured", from, strings.Join(missing, ", ")))
+		}
+	}
+
+	regions := GetRegionCatalog().Probed()
+	var unreachable, missing []string
+	for _, region := range regions {
+		rtt, exists := locations[region]
+		if !exists {
+			missing = append(missing, region)
+		} else if IsUnreachable(rtt) {
+			unreachable = append(unreachable, region)
+		}
+	}
+	report(ClientNode, unreachable, missing)
+
+	// region pairs are reported once, under the first region of the pair
+	for i, datacenter := range regions {
+		unreachable, missing = nil, nil
+		for _, edge := range regions[i+1:] {
+			rtt, exists := edges[datacenter][edge]
+			if !exists {
+				rtt, exists = edges[edge][datacenter]
+			}
+			if !exists {
+				missing = append(missing, edge)
+			} else if IsUnreachable(rtt) {
+				unreachable = append(unreachable, edge)
+			}
+		}
+		report(datacenter, unreachable, missing)
+	}
+	return gaps
+}
+
+// Great-circle distance between two regions with known coordinates
+func regionDistance(a string, b string) (float64, bool) {
+	pointA, existsA := regionLocation(a)
+	pointB, existsB := regionLocation(b)
+	if !existsA || !existsB {
+		return 0, false
+	}
+	return GreatCircleKm(pointA, pointB), true
+}
+
+func sortedEdgeKeys(edges map[string]map[string]float64) []string {
+	keys := make([]string, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
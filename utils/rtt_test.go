@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"radsched/common"
+)
+
+func TestMeasurementGaps(t *testing.T) {
+	regions := GetRegionCatalog().Probed()
+	if len(regions) != 4 {
+		t.Fatalf("fixture has regions %v, want 4", regions)
+	}
+	a, b, c, d := regions[0], regions[1], regions[2], regions[3]
+	locations := map[string]float64{a: 10, b: UnreachableRTT, c: 30}
+	edges := map[string]map[string]float64{
+		a: {b: 20, c: UnreachableRTT, d: 40},
+		b: {c: 25},
+		d: {b: 35}, // measured from the other end
+	}
+	got := MeasurementGaps(locations, edges)
+	want := []string{
+		ClientNode + " -> " + b + ": unreachable",
+		ClientNode + " -> " + d + ": not measured",
+		a + " -> " + c + ": unreachable",
+		c + " -> " + d + ": not measured",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if gaps := MeasurementGaps(nil, nil); len(gaps) != len(regions) {
+		t.Errorf("nothing measured: %q, want the client and every region but the last", gaps)
+	}
+}
+
+// Unreachable links rule an edge out by default, or add the configured penalty
+func TestUnreachableCandidates(t *testing.T) {
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	function := functions[0]
+	measured, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge := ""
+	for _, region := range GetRegionCatalog().Probed() {
+		if region != function.Datacenter {
+			edge = region
+			break
+		}
+	}
+	locations := make(map[string]float64)
+	for location, rtt := range measured {
+		locations[location] = rtt
+	}
+	locations[edge] = UnreachableRTT
+
+	candidates := make(map[string]map[string]float64) // mode -> location -> estimate
+	for _, mode := range []string{"exclude", "penalize"} {
+		withConfig(t, func(cfg *Config) {
+			cfg.LatencyModel.Unreachable = mode
+			cfg.LatencyModel.UnreachablePenaltyMs = 1000
+		})
+		decision, err := RunOptLatencyForClient(function, locations, PolicyOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates[mode] = make(map[string]float64)
+		for _, candidate := range decision.Candidates {
+			candidates[mode][candidate.Location] = candidate.Estimate
+			if candidate.Location != edge {
+				continue
+			}
+			if candidate.RTTStatus != string(RTTUnreachable) {
+				t.Errorf("%s: status %s, want unreachable", mode, candidate.RTTStatus)
+			}
+			if filtered := mode == "exclude"; candidate.Filtered != filtered {
+				t.Errorf("%s: filtered %t, want %t", mode, candidate.Filtered, filtered)
+			}
+			if mode == "exclude" && candidate.Reason != "client unreachable" {
+				t.Errorf("excluded for %q", candidate.Reason)
+			}
+		}
+		if decision.OptLocation == edge {
+			t.Errorf("%s: placed at the unreachable edge", mode)
+		}
+	}
+	if _, exists := candidates["exclude"][edge]; !exists {
+		t.Fatalf("%s is not a candidate", edge)
+	}
+	for location, estimate := range candidates["exclude"] {
+		want := estimate
+		if location == edge {
+			want += 1000
+		}
+		if got := candidates["penalize"][location]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: penalized estimate %v, want %v", location, got, want)
+		}
+	}
+}
+
+// Probes without an answer and unreadable RTTs both load as unreachable
+func TestGetLocationsUnreachable(t *testing.T) {
+	withDataCopy(t)
+	probes := []common.LocationInfo{
+		{LocationName: "us-east-1", RoundTripTime: "12.5 ms"},
+		{LocationName: " EU-West-1 ", RoundTripTime: "-1"},
+		{LocationName: "ap-south-1", RoundTripTime: "timeout"},
+		{LocationName: "sa-east-1", RoundTripTime: "-3.0 ms"},
+	}
+	if err := writeDataFile(DataPath(ClientEdgeRTTFile), probes); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"us-east-1": 12.5, "eu-west-1": UnreachableRTT, "ap-south-1": UnreachableRTT, "sa-east-1": UnreachableRTT}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"radsched/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Body of a POST /schedule request
+type ScheduleRequest struct {
+	Function string    `json:"function"`
+	Weighted bool      `json:"weighted"`
+	Schedule string    `json:"schedule,omitempty"` // exploration schedule for weighted requests
+	Seed     *int64    `json:"seed,omitempty"`     // seeds the exploration draw; the decision still advances epsilon and is logged
+	Replay   string    `json:"replay,omitempty"`   // ID of a logged decision to repeat without recording anything
+	Explain  bool      `json:"explain,omitempty"`  // include every candidate in the response
+	Client   ClientRef `json:"client"`
+}
+
+type ScheduleResponse struct {
+	Function       string                 `json:"function"`
+	Location       string                 `json:"location"`
+	ExecutionTime  float64                `json:"execution_time"`
+	Cost           string                 `json:"cost_usd,omitempty"`
+	Explored       bool                   `json:"explored"`
+	Epsilon        float64                `json:"epsilon,omitempty"`
+	Seed           int64                  `json:"seed,omitempty"`
+	ClientRTTs     string                 `json:"client_rtts"`               // where the client's RTTs came from
+	DecisionID     string                 `json:"decision_id,omitempty"`     // reports the outcome to POST /outcome
+	LoggedLocation string                 `json:"logged_location,omitempty"` // location the replayed decision chose when it was made
+	Candidates     []common.CandidateInfo `json:"candidates,omitempty"`
+}
+
+// Body of a POST /outcome request, reporting what happened after a decision
+type OutcomeRequest struct {
+	DecisionID   string  `json:"decision_id"`
+	Latency      float64 `json:"latency_ms"`
+	Inconsistent *bool   `json:"inconsistent,omitempty"`
+}
+
+// Schedules functions for many clients over HTTP, choosing per client from
+// the RTT profile that matches the caller
+type ScheduleServer struct {
+	GeoIP   *GeoIPDatabase // locates callers no profile matches; may be nil
+	Metrics *Metrics       // served on /metrics; may be nil
+
+	// proxies whose X-Forwarded-For is used to find the caller; other callers are taken at their address
+	TrustedProxies []*net.IPNet
+
+	// decisions read and update epsilon and warmer state on disk, so they run one at a time
+	mu sync.Mutex
+}
+
+func (s *ScheduleServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/schedule", s.serveSchedule)
+	mux.HandleFunc("/outcome", s.serveOutcome)
+	if s.Metrics != nil {
+		mux.Handle("/metrics", s.Metrics)
+	}
+	return mux
+}
+
+func (s *ScheduleServer) serveSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.Client.IP == "" {
+		request.Client.IP = s.callerIP(r)
+	}
+
+	response, status, err := s.schedule(request)
+	if err != nil {
+		if s.Metrics != nil {
+			s.Metrics.ObserveError()
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, response)
+}
+
+func (s *ScheduleServer) serveOutcome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request OutcomeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.DecisionID == "" {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	outcome := DecisionOutcome{Latency: request.Latency, Inconsistent: request.Inconsistent}
+	if err := RecordDecisionOutcome(request.DecisionID, outcome); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.Metrics != nil {
+		s.Metrics.ObserveOutcome(outcome)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ScheduleServer) schedule(request ScheduleRequest) (ScheduleResponse, int, error) {
+	if request.Replay != "" {
+		return s.replay(request.Replay)
+	}
+	request.Function = strings.ToLower(request.Function)
+	request.Schedule = strings.ToUpper(request.Schedule)
+	if request.Schedule != "" && !IsSchedule(request.Schedule) {
+		return ScheduleResponse{}, http.StatusBadRequest, fmt.Errorf("unknown exploration schedule %s", request.Schedule)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	functions, err := GetFunctionsAsMap()
+	if err != nil {
+		return ScheduleResponse{}, http.StatusInternalServerError, fmt.Errorf("failed to fetch function info: %v", err)
+	}
+	function, exists := functions[request.Function]
+	if !exists {
+		return ScheduleResponse{}, http.StatusNotFound, fmt.Errorf("function %s is unknown", request.Function)
+	}
+	profiles, err := LoadClientProfiles()
+	if err != nil {
+		return ScheduleResponse{}, http.StatusInternalServerError, err
+	}
+	client, source, err := ResolveClient(request.Client, profiles, s.GeoIP)
+	if err != nil {
+		return ScheduleResponse{}, http.StatusInternalServerError, err
+	}
+	clientRTTs := client.RTTs
+
+	var executionInfo common.ExecutionInfo
+	if request.Weighted {
+		seed := time.Now().UnixNano()
+		if request.Seed != nil {
+			seed = *request.Seed
+		}
+		opts := NewPolicyOptions(request.Schedule, seed)
+		opts.Region = client.Region
+		executionInfo, err = RunOptWeightedLatencyForClient(function, clientRTTs, opts)
+	} else {
+		executionInfo, err = RunOptLatencyForClient(function, clientRTTs, PolicyOptions{})
+	}
+	if err != nil {
+		return ScheduleResponse{}, http.StatusInternalServerError, err
+	}
+	if err := RecordPlacement(request.Function, executionInfo.OptLocation); err != nil {
+		log.Printf("Failed to record placement: %v", err)
+	}
+	policy := "latency"
+	if request.Weighted {
+		policy = "weighted"
+	}
+	record := NewDecisionRecord(time.Now(), request.Function, request.Client.label(), policy, executionInfo)
+	if executionInfo.DecisionID, err = RecordDecision(record); err != nil {
+		log.Printf("Failed to record decision: %v", err)
+	}
+	if s.Metrics != nil {
+		s.Metrics.ObserveDecision(policy, executionInfo.OptLocation, executionInfo.Explored, executionInfo.ExecutionTime)
+	}
+
+	response := ScheduleResponse{
+		Function:      request.Function,
+		Location:      executionInfo.OptLocation,
+		ExecutionTime: executionInfo.ExecutionTime,
+		Cost:          executionInfo.Cost,
+		Explored:      executionInfo.Explored,
+		Epsilon:       executionInfo.Epsilon,
+		Seed:          executionInfo.Seed,
+		ClientRTTs:    source,
+		DecisionID:    executionInfo.DecisionID,
+	}
+	if request.Explain {
+		response.Candidates = executionInfo.Candidates
+	}
+	return response, http.StatusOK, nil
+}
+
+// Repeats a logged decision from its seed, epsilon and candidates, like run
+// --replay. Nothing is recorded: exploration and warmer state, the decision
+// log and the metrics are left as they were.
+func (s *ScheduleServer) replay(id string) (ScheduleResponse, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := FindDecision(id)
+	if err != nil {
+		return ScheduleResponse{}, http.StatusNotFound, err
+	}
+	functions, err := GetFunctionsAsMap()
+	if err != nil {
+		return ScheduleResponse{}, http.StatusInternalServerError, fmt.Errorf("failed to fetch function info: %v", err)
+	}
+	function, exists := functions[record.Function]
+	if !exists {
+		return ScheduleResponse{}, http.StatusNotFound, fmt.Errorf("function %s of decision %s is no longer registered", record.Function, id)
+	}
+	executionInfo, err := ReplayDecision(function, record)
+	if err != nil {
+		return ScheduleResponse{}, http.StatusBadRequest, err
+	}
+	return ScheduleResponse{
+		Function:       record.Function,
+		Location:       executionInfo.OptLocation,
+		ExecutionTime:  executionInfo.ExecutionTime,
+		Explored:       executionInfo.Explored,
+		Epsilon:        executionInfo.Epsilon,
+		Seed:           executionInfo.Seed,
+		ClientRTTs:     "replay",
+		DecisionID:     record.ID,
+		LoggedLocation: record.Location,
+	}, http.StatusOK, nil
+}
+
+// Address of the caller. Behind a trusted proxy this is the nearest
+// X-Forwarded-For hop that is not itself a trusted proxy; the header of any
+// other caller is ignored, since it could claim any address.
+func (s *ScheduleServer) callerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !s.trusted(host) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !s.trusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+func (s *ScheduleServer) trusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses trusted proxy IPs and CIDRs; a bare IP trusts only that address
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// X-Forwarded-For is only believed from trusted proxies, and then only up to
+// the nearest hop that is not one
+func TestCallerIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &ScheduleServer{TrustedProxies: proxies}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct caller", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"untrusted caller claiming an address", "203.0.113.5:4000", "198.51.100.7", "203.0.113.5"},
+		{"behind a trusted proxy", "10.0.0.1:4000", "198.51.100.7", "198.51.100.7"},
+		{"through a chain of proxies", "10.0.0.1:4000", "198.51.100.7, 10.1.1.1", "198.51.100.7"},
+		{"spoofed first hop", "10.0.0.1:4000", "1.2.3.4, 198.51.100.7, 10.1.1.1", "198.51.100.7"},
+		{"trusted single address", "192.0.2.1:4000", "198.51.100.7", "198.51.100.7"},
+		{"neighbour of a trusted address", "192.0.2.2:4000", "198.51.100.7", "192.0.2.2"},
+		{"proxy without a header", "10.0.0.1:4000", "", "10.0.0.1"},
+		{"only proxies", "10.0.0.1:4000", "10.2.2.2, 10.1.1.1", "10.2.2.2"},
+		{"empty hops", "10.0.0.1:4000", "198.51.100.7, ,", "198.51.100.7"},
+		{"IPv6 proxy", "[2001:db8::1]:4000", "198.51.100.7", "198.51.100.7"},
+		{"address without a port", "203.0.113.5", "198.51.100.7", "203.0.113.5"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/schedule", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			if got := server.callerIP(r); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	untrusting := &ScheduleServer{}
+	r := httptest.NewRequest("POST", "/schedule", nil)
+	r.RemoteAddr = "10.0.0.1:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got := untrusting.callerIP(r); got != "10.0.0.1" {
+		t.Errorf("no trusted proxies: got %s, want 10.0.0.1", got)
+	}
+}
+
+func TestParseTrustedProxiesErrors(t *testing.T) {
+	for _, proxy := range []string{"proxy.internal", "10.0.0.0/33", "300.1.1.1"} {
+		if _, err := ParseTrustedProxies([]string{proxy}); err == nil {
+			t.Errorf("%q: expected an error", proxy)
+		}
+	}
+}
+
+// A replay request repeats a logged weighted decision and changes nothing,
+// while a seeded request is a new decision
+func TestScheduleReplay(t *testing.T) {
+	withDataCopy(t)
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := NewMetrics()
+	server := httptest.NewServer((&ScheduleServer{Metrics: metrics}).Handler())
+	defer server.Close()
+	post := func(request ScheduleRequest) (ScheduleResponse, int) {
+		body, _ := json.Marshal(request)
+		resp, err := http.Post(server.URL+"/schedule", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var response ScheduleResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return response, resp.StatusCode
+	}
+
+	seed := int64(7)
+	request := ScheduleRequest{Function: functions[0].FunctionName, Weighted: true, Schedule: INVERSE_N, Seed: &seed, Client: ClientRef{Region: "eu-west-1"}}
+	decided, status := post(request)
+	if status != http.StatusOK || decided.DecisionID == "" {
+		t.Fatalf("status %d, response %+v", status, decided)
+	}
+	if again, _ := post(request); again.DecisionID == decided.DecisionID || again.Epsilon == decided.Epsilon {
+		t.Errorf("seeded request gave %+v after %+v, want a new decision with the next epsilon", again, decided)
+	}
+
+	before := dataFiles(t)
+	metrics.mu.Lock()
+	decisions := len(metrics.decisions)
+	metrics.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		replayed, status := post(ScheduleRequest{Replay: decided.DecisionID})
+		if status != http.StatusOK {
+			t.Fatalf("replay status %d", status)
+		}
+		if replayed.Location != decided.Location || replayed.LoggedLocation != decided.Location ||
+			replayed.Epsilon != decided.Epsilon || replayed.Seed != seed || replayed.DecisionID != decided.DecisionID {
+			t.Errorf("replayed %+v, want the logged decision %+v", replayed, decided)
+		}
+	}
+	if after := dataFiles(t); !reflect.DeepEqual(before, after) {
+		t.Error("replaying changed the data files")
+	}
+	metrics.mu.Lock()
+	if len(metrics.decisions) != decisions || metrics.actions["explore"]+metrics.actions["exploit"] != 2 {
+		t.Errorf("replays were counted: %v", metrics.actions)
+	}
+	metrics.mu.Unlock()
+
+	if _, status := post(ScheduleRequest{Replay: "no-such-decision"}); status != http.StatusNotFound {
+		t.Errorf("unknown decision: status %d, want 404", status)
+	}
+}
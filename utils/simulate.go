@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"radsched/common"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// One request of a trace; Client is a client profile ID or a region
+type TraceRequest struct {
+	Timestamp time.Time `json:"timestamp"`
+	Function  string    `json:"function"`
+	Client    string    `json:"client"`
+}
+
+// Aggregate outcome of replaying a trace under one policy
+type SimulationResult struct {
+	Policy            string
+	Requests          int
+	Skipped           int // requests for unknown functions
+	MeanLatency       float64
+	P50Latency        float64
+	P99Latency        float64
+	InconsistencyRate float64 // requests served at an edge that returned inconsistent state
+	TotalCostUSD      float64
+	Explorations      int
+	MeanRegret        float64 // ms lost per request against the best location under the simulated network
+	Decisions         []DecisionRecord
+}
+
+// Reads a trace with one JSON request per line, sorted by timestamp
+func LoadTrace(path string) ([]TraceRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace: %v", err)
+	}
+	defer file.Close()
+
+	var trace []TraceRequest
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var request TraceRequest
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return nil, fmt.Errorf("failed to parse trace line %d: %v", line, err)
+		}
+		request.Function = strings.ToLower(request.Function)
+		request.Client = strings.ToLower(request.Client)
+		trace = append(trace, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trace: %v", err)
+	}
+	sort.SliceStable(trace, func(i, j int) bool { return trace[i].Timestamp.Before(trace[j].Timestamp) })
+	return trace, nil
+}
+
+// Replays a trace against the RTT, consistency and function data in the data
+// directory without changing it. When the directory holds network snapshots,
+// each request sees the RTTs and consistency of the snapshot current at its
+// timestamp. A request's latency is sampled at the chosen location from those
+// RTTs and the recorded execution times, with noise the policies cannot see, and
+// a cold start drawn from its probability. Regret is measured against the
+// location with the lowest sampled latency. An
+// edge returns inconsistent state with its consistency failure ratio (0.5 when
+// unknown). The same seed gives the same result.
+func Simulate(trace []TraceRequest, policyName string, schedule string, seed int64) (SimulationResult, error) {
+	result := SimulationResult{Policy: policyName}
+	policy, err := GetPolicy(policyName)
+	if err != nil {
+		return result, err
+	}
+	functions, err := GetFunctionsAsMap()
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch function info: %v", err)
+	}
+	profiles, err := LoadClientProfiles()
+	if err != nil {
+		return result, err
+	}
+	snapshots, err := LoadNetworkSnapshots()
+	if err != nil {
+		return result, err
+	}
+
+	state := NewPolicyState()
+	policyRand := rand.New(rand.NewSource(seed))
+	consistencyRand := rand.New(rand.NewSource(seed + 1))
+	outcomeRand := rand.New(rand.NewSource(seed + 2))
+	clients := make(map[string]ClientRef)
+	var latencies []float64
+	var regret float64
+	inconsistent := 0
+
+	for _, request := range trace {
+		function, exists := functions[request.Function]
+		if !exists {
+			result.Skipped++
+			continue
+		}
+		client, cached := clients[request.Client]
+		if !cached {
+			client, _, err = ResolveClient(ClientRef{ID: request.Client, Region: request.Client}, profiles, nil)
+			if err != nil {
+				return result, err
+			}
+			clients[request.Client] = client
+		}
+		rtts := client.RTTs
+		network := snapshots.At(request.Timestamp)
+		if snapshotRTTs := network.clientRTTs(request.Client, profiles); snapshotRTTs != nil {
+			rtts = snapshotRTTs
+		}
+
+		opts := PolicyOptions{Schedule: schedule, Region: client.Region, Seed: seed, Rand: policyRand, Now: request.Timestamp, State: state, Network: network}
+		decision, err := policy(function, rtts, opts)
+		if err != nil {
+			return result, err
+		}
+		outcomes, err := sampleOutcomes(function, rtts, PolicyOptions{Now: request.Timestamp, State: state, Network: network}, outcomeRand)
+		if err != nil {
+			return result, err
+		}
+		latency, exists := outcomes[decision.OptLocation]
+		if !exists {
+			return result, fmt.Errorf("%s policy chose %s, where %s cannot run", policyName, decision.OptLocation, function.FunctionName)
+		}
+		best := math.MaxFloat64
+		for _, outcome := range outcomes {
+			best = math.Min(best, outcome)
+		}
+
+		latencies = append(latencies, latency)
+		regret += latency - best
+		if decision.Explored {
+			result.Explorations++
+		}
+		if cost, err := strconv.ParseFloat(decision.Cost, 64); err == nil {
+			result.TotalCostUSD += cost
+		}
+		wasInconsistent := false
+		if decision.OptLocation != function.Datacenter {
+			failureRatio, err := opts.consistencyWeight(decision.OptLocation, function.FunctionName)
+			if err != nil {
+				return result, err
+			}
+			if consistencyRand.Float64() < failureRatio {
+				inconsistent++
+				wasInconsistent = true
+			}
+		}
+		record := NewDecisionRecord(request.Timestamp, function.FunctionName, request.Client, policyName, decision)
+		record.Outcome = &DecisionOutcome{Latency: latency, Inconsistent: &wasInconsistent}
+		result.Decisions = append(result.Decisions, record)
+		state.RecordInvocation(function.FunctionName, decision.OptLocation, request.Timestamp)
+	}
+
+	result.Requests = len(latencies)
+	if result.Requests == 0 {
+		return result, nil
+	}
+	result.MeanLatency = Mean(latencies)
+	result.P50Latency = Quantile(latencies, 0.5)
+	result.P99Latency = Quantile(latencies, 0.99)
+	result.InconsistencyRate = float64(inconsistent) / float64(result.Requests)
+	result.MeanRegret = regret / float64(result.Requests)
+	return result, nil
+}
+
+// Relative standard deviation of the noise on each sampled RTT, and on the
+// declared execution time of a function with no recorded samples at a location
+const outcomeJitter = 0.1
+
+// Latency a request would see at the primary datacenter and at every edge the
+// function may run at, under the simulated network. The policy plans with its
+// estimates, so the outcome is drawn independently of them: each link's matrix
+// RTT gets its own noise, the execution time is a random sample recorded at the
+// location, or the declared time with noise if none was recorded, and the cold
+// start penalty is added if a draw with the cold start probability comes up
+// cold. A policy can therefore lose to a location it estimated to be slower.
+func sampleOutcomes(function common.FunctionInfo, clientRTTs map[string]float64, opts PolicyOptions, rng *rand.Rand) (map[string]float64, error) {
+	edges, err := opts.edges()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load edge data: %v", err)
+	}
+	rtts := newRTTModel(clientRTTs, edges)
+	estimator, err := newLatencyEstimator(function, rtts, opts)
+	if err != nil {
+		return nil, err
+	}
+	model := GetConfig().LatencyModel
+	jitter := func(value float64) float64 {
+		return math.Max(0, value*(1+rng.NormFloat64()*outcomeJitter))
+	}
+	executionTime := func(location string) float64 {
+		if profile := estimator.profiles[strings.ToLower(location)]; profile != nil && len(profile.Samples) > 0 {
+			return profile.Samples[rng.Intn(len(profile.Samples))]
+		}
+		return jitter(function.ExecutionTime.Milliseconds())
+	}
+	coldStart := func(location string) float64 {
+		coldProbability, coldPenalty := estimator.coldStart(location)
+		if rng.Float64() < coldProbability {
+			return coldPenalty
+		}
+		return 0
+	}
+
+	outcomes := make(map[string]float64)
+	candidates, _ := FilterCandidates(function, rtts.clientRTTMap())
+	for _, edge := range sortedKeys(candidates) {
+		candidate := estimator.edgeCandidate(edge)
+		if candidate.Filtered {
+			continue
+		}
+		edgeToDatacenter := jitter(math.Max(candidate.EdgeRTT, 0))
+		stateTime := function.StateAccess.Writes() * edgeToDatacenter
+		validation := edgeToDatacenter + model.ValidationOverheadMs
+		latency := jitter(math.Max(candidate.ClientRTT, 0)) + candidate.TransferTime +
+			max(executionTime(edge)+stateTime, validation) + coldStart(edge)
+		if candidate.Reason != "" {
+			// penalized rather than excluded
+			latency += model.UnreachablePenaltyMs
+		}
+		outcomes[edge] = latency
+	}
+
+	datacenter := function.Datacenter
+	clientRTT := rtts.clientRTT(datacenter)
+	latency := jitter(math.Max(clientRTT.RTT, 0)) + transferTime(function.Payload, linkBandwidth(datacenter, estimator.bandwidth)) +
+		executionTime(datacenter) + float64(function.StateAccess.Accesses())*model.DatacenterAccessMs + coldStart(datacenter)
+	if unusableLinkReason("client", clientRTT.Status) != "" {
+		latency += model.UnreachablePenaltyMs
+	}
+	outcomes[datacenter] = latency
+	return outcomes, nil
+}
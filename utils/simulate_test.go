@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// Replays the fixture's generated trace, then runs the same functions through
+// the mock provider
+func TestSimulateSyntheticTopology(t *testing.T) {
+	trace, err := LoadTrace(DataPath(TraceFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace) == 0 {
+		t.Fatal("the generated trace is empty")
+	}
+
+	results := make(map[string]SimulationResult)
+	for _, policy := range []string{"latency", "weighted", "datacenter"} {
+		result, err := Simulate(trace, policy, INVERSE_N, 7)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		results[policy] = result
+		if result.Requests != len(trace) || result.Skipped != 0 {
+			t.Errorf("%s: %d requests and %d skipped, want %d and 0", policy, result.Requests, result.Skipped, len(trace))
+		}
+		if len(result.Decisions) != result.Requests {
+			t.Errorf("%s: %d decisions for %d requests", policy, len(result.Decisions), result.Requests)
+		}
+		if result.MeanLatency <= 0 || result.P50Latency > result.P99Latency {
+			t.Errorf("%s: mean %v, p50 %v, p99 %v", policy, result.MeanLatency, result.P50Latency, result.P99Latency)
+		}
+		if result.MeanRegret < -1e-9 {
+			t.Errorf("%s: negative regret %v", policy, result.MeanRegret)
+		}
+		if result.InconsistencyRate < 0 || result.InconsistencyRate > 1 {
+			t.Errorf("%s: inconsistency rate %v", policy, result.InconsistencyRate)
+		}
+	}
+	if results["latency"].MeanRegret >= results["weighted"].MeanRegret {
+		t.Errorf("the latency policy has regret %v, no less than the exploring weighted policy's %v",
+			results["latency"].MeanRegret, results["weighted"].MeanRegret)
+	}
+	if results["datacenter"].InconsistencyRate != 0 {
+		t.Errorf("the datacenter returned inconsistent state")
+	}
+	if results["latency"].Explorations != 0 || results["weighted"].Explorations == 0 {
+		t.Errorf("explorations: latency %d, weighted %d", results["latency"].Explorations, results["weighted"].Explorations)
+	}
+
+	replay, err := Simulate(trace, "weighted", INVERSE_N, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.MeanLatency != results["weighted"].MeanLatency || replay.Explorations != results["weighted"].Explorations {
+		t.Errorf("the same seed gave mean %v with %d explorations, then %v with %d",
+			results["weighted"].MeanLatency, results["weighted"].Explorations, replay.MeanLatency, replay.Explorations)
+	}
+
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientRTTs, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := RunBench(functions, []string{"latency"}, 2, MockProvider{}, INVERSE_N, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Trials) != 2*2*len(functions) {
+		t.Errorf("%d trials, want %d", len(report.Trials), 2*2*len(functions))
+	}
+	executionTimes := make(map[string]float64)
+	for _, function := range functions {
+		executionTimes[function.FunctionName] = function.ExecutionTime.Milliseconds()
+	}
+	for _, trial := range report.Trials {
+		want := clientRTTs[trial.Location] + executionTimes[trial.Function]
+		if trial.Error != "" || math.Abs(trial.Observed-want) > 1e-9 {
+			t.Errorf("%s under %s at %s: observed %v (%s), want %v", trial.Function, trial.Policy, trial.Location, trial.Observed, trial.Error, want)
+		}
+	}
+}
+
+// The outcomes are drawn independently of the estimates the latency policy
+// chose by, so over many draws it sometimes loses to another location and its
+// regret is above zero
+func TestSampleOutcomes(t *testing.T) {
+	functions, err := GetFunctionsAsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientRTTs, err := GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := fixtureOptions.Start.Add(time.Hour)
+	tests := []struct {
+		name    string
+		invoked time.Time // last invocation at every location
+	}{
+		{"warm everywhere", now.Add(-time.Minute)},
+		{"never invoked", time.Time{}},
+	}
+	const draws = 200
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			regret, lost := 0.0, 0
+			for _, function := range functions {
+				state := NewPolicyState()
+				if !test.invoked.IsZero() {
+					for location := range clientRTTs {
+						state.RecordInvocation(function.FunctionName, location, test.invoked)
+					}
+				}
+				opts := PolicyOptions{Now: now, State: state}
+				decision, err := RunOptLatencyForClient(function, clientRTTs, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				differs := false
+				for i := 0; i < draws; i++ {
+					outcomes, err := sampleOutcomes(function, clientRTTs, opts, rng)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if _, exists := outcomes[function.Datacenter]; !exists {
+						t.Fatalf("%s: no outcome for the datacenter %s", function.FunctionName, function.Datacenter)
+					}
+					best := math.MaxFloat64
+					for _, outcome := range outcomes {
+						best = math.Min(best, outcome)
+					}
+					got := outcomes[decision.OptLocation]
+					regret += got - best
+					if got > best {
+						lost++
+					}
+					if math.Abs(got-decision.ExecutionTime) > 1e-9 {
+						differs = true
+					}
+				}
+				if !differs {
+					t.Errorf("%s at %s: every outcome equals the estimate %v", function.FunctionName, decision.OptLocation, decision.ExecutionTime)
+				}
+			}
+			// a location far ahead of the rest still wins every draw, but not all do
+			if lost == 0 || regret <= 0 {
+				t.Errorf("lost %d of %d draws with regret %v, want some regret", lost, draws*len(functions), regret)
+			}
+		})
+	}
+}
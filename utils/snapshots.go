@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+const NetworkSnapshotsFile = "network_snapshots.json"
+
+// RTTs and consistency failure ratios as they were at one time
+type NetworkSnapshot struct {
+	At          time.Time                     `json:"at"`
+	Clients     map[string]map[string]float64 `json:"clients"`     // client profile ID -> region -> RTT
+	Edges       map[string]map[string]float64 `json:"edges"`       // datacenter -> edge -> RTT, as in edge_datacenter_rtts.json
+	Consistency map[string]map[string]float64 `json:"consistency"` // edge -> function -> failure ratio
+}
+
+// Snapshots sorted by time
+type NetworkSnapshots []NetworkSnapshot
+
+// Reads the time-indexed snapshots written by generate; none if there is no file
+func LoadNetworkSnapshots() (NetworkSnapshots, error) {
+	var snapshots NetworkSnapshots
+	_, err := readDataFile(DataPath(NetworkSnapshotsFile), &snapshots)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read network snapshots: %v", err)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].At.Before(snapshots[j].At) })
+	return snapshots, nil
+}
+
+// The last snapshot taken at or before t, or the first one if t precedes them
+// all; nil if there are none
+func (snapshots NetworkSnapshots) At(t time.Time) *NetworkSnapshot {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	i := sort.Search(len(snapshots), func(i int) bool { return snapshots[i].At.After(t) })
+	if i == 0 {
+		return &snapshots[0]
+	}
+	return &snapshots[i-1]
+}
+
+// Failure ratio of the function at an edge, 0.5 when unknown like the recorded statistics
+func (snapshot *NetworkSnapshot) consistencyWeight(edge string, function string) float64 {
+	ratio, exists := snapshot.Consistency[edge][function]
+	if !exists {
+		return 0.5
+	}
+	return ratio
+}
+
+// RTTs of a trace client, named by profile ID or region, in the snapshot; nil
+// if the snapshot is nil or has none for it
+func (snapshot *NetworkSnapshot) clientRTTs(client string, profiles []ClientProfile) map[string]float64 {
+	if snapshot == nil {
+		return nil
+	}
+	if rtts, exists := snapshot.Clients[client]; exists {
+		return rtts
+	}
+	for _, profile := range profiles {
+		if profile.Region == client {
+			return snapshot.Clients[profile.ID]
+		}
+	}
+	return nil
+}
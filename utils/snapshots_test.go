@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNetworkSnapshotsAt(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	snapshots := NetworkSnapshots{{At: start}, {At: start.Add(time.Hour)}, {At: start.Add(2 * time.Hour)}}
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"before the first", start.Add(-time.Minute), start},
+		{"at the first", start, start},
+		{"within the first hour", start.Add(59 * time.Minute), start},
+		{"at the second", start.Add(time.Hour), start.Add(time.Hour)},
+		{"after the last", start.Add(5 * time.Hour), start.Add(2 * time.Hour)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := snapshots.At(test.at); !got.At.Equal(test.want) {
+				t.Errorf("At(%v) = snapshot at %v, want %v", test.at, got.At, test.want)
+			}
+		})
+	}
+	if got := NetworkSnapshots(nil).At(start); got != nil {
+		t.Errorf("no snapshots gave %v, want nil", got)
+	}
+}
+
+// The fixture writes one snapshot per hour whose RTTs and consistency change
+// over the window, starting from the data files bootstrap would have written
+func TestGeneratedSnapshotsVary(t *testing.T) {
+	snapshots, err := LoadNetworkSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != int(fixtureOptions.Hours) {
+		t.Fatalf("%d snapshots, want one per hour of %v", len(snapshots), fixtureOptions.Hours)
+	}
+	for i, snapshot := range snapshots {
+		if want := fixtureOptions.Start.Add(time.Duration(i) * time.Hour); !snapshot.At.Equal(want) {
+			t.Errorf("snapshot %d at %v, want %v", i, snapshot.At, want)
+		}
+	}
+
+	edges, err := GetEdges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	rttsChanged, consistencyChanged := false, false
+	for datacenter, rtts := range first.Edges {
+		for edge, rtt := range rtts {
+			if edges[datacenter][edge] != rtt {
+				t.Errorf("%s -> %s is %v in the data files but %v in the first snapshot", datacenter, edge, edges[datacenter][edge], rtt)
+			}
+			rttsChanged = rttsChanged || last.Edges[datacenter][edge] != rtt
+		}
+	}
+	for edge, ratios := range first.Consistency {
+		for function, ratio := range ratios {
+			if ratio < 0 || ratio > 1 {
+				t.Errorf("%s/%s has failure ratio %v", edge, function, ratio)
+			}
+			consistencyChanged = consistencyChanged || last.Consistency[edge][function] != ratio
+		}
+	}
+	if !rttsChanged || !consistencyChanged {
+		t.Errorf("over the window RTTs changed: %v, consistency changed: %v", rttsChanged, consistencyChanged)
+	}
+}
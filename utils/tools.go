@@ -1,14 +1,13 @@
 package utils
 
-func RemoveLocationsExcept(locations map[string]float64, exceptions []string) (map[string]float64) {
-	exceptionSet := make(map[string]int)
-	for _, key := range exceptions {
-		exceptionSet[key] = 1
-	}
-	for key := range locations {
-		if _, exists := exceptionSet[key]; !exists {
-			delete(locations, key)
-		}
+import "sort"
+
+// Map keys in sorted order, so ties and random draws do not depend on map iteration
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
-	return locations
-}
\ No newline at end of file
+	sort.Strings(keys)
+	return keys
+}
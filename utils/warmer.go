@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"radsched/common"
+	"sort"
+	"strings"
+	"time"
+)
+
+const WarmerStateFile = "warmer.json"
+
+// Where a function was placed and when
+type PlacementRecord struct {
+	Location string    `json:"location"`
+	At       time.Time `json:"at"`
+}
+
+// Recent placements and the warm-up budget, persisted between warmer rounds
+type WarmerState struct {
+	Placements   map[string][]PlacementRecord `json:"placements"`
+	BudgetWindow time.Time                    `json:"budget_window"` // start of the current hourly budget window
+	BudgetSpent  int                          `json:"budget_spent"`
+	WarmupsSent  int                          `json:"warmups_sent"`
+	WarmupErrors int                          `json:"warmup_errors"`
+}
+
+// A location the warmer keeps warm for a function
+type WarmupTarget struct {
+	Function string
+	Location string
+	Forecast float64 // expected invocations per hour
+}
+
+// Outcome of one warmer round
+type WarmRound struct {
+	Sent            []WarmupTarget
+	StillWarm       int
+	BudgetExhausted bool
+	Errors          []error
+}
+
+func LoadWarmerState() (WarmerState, error) {
+	state := WarmerState{Placements: make(map[string][]PlacementRecord)}
+	_, err := readDataFile(DataPath(WarmerStateFile), &state)
+	if err != nil && !os.IsNotExist(err) {
+		return state, fmt.Errorf("failed to read warmer state: %v", err)
+	}
+	if state.Placements == nil {
+		state.Placements = make(map[string][]PlacementRecord)
+	}
+	return state, nil
+}
+
+func SaveWarmerState(state WarmerState) error {
+	if err := writeDataFile(DataPath(WarmerStateFile), state); err != nil {
+		return fmt.Errorf("failed to write warmer state: %v", err)
+	}
+	return nil
+}
+
+// Remembers a placement decision so the warmer can forecast traffic
+func RecordPlacement(function string, location string) error {
+	return updateDataFile(WarmerStateFile, func() error {
+		state, err := LoadWarmerState()
+		if err != nil {
+			return err
+		}
+		function = strings.ToLower(function)
+		placements := append(state.Placements[function], PlacementRecord{Location: location, At: time.Now().UTC()})
+		if limit := GetConfig().Warmer.MaxPlacements; limit > 0 && len(placements) > limit {
+			placements = placements[len(placements)-limit:]
+		}
+		state.Placements[function] = placements
+		return SaveWarmerState(state)
+	})
+}
+
+// Expected invocations per hour: the average of the last hour and the whole lookback window
+func ForecastRate(placements []PlacementRecord, now time.Time, lookbackHours float64) float64 {
+	lastHour, lookback := 0, 0
+	for _, placement := range placements {
+		age := now.Sub(placement.At).Hours()
+		if age <= lookbackHours {
+			lookback++
+		}
+		if age <= 1 {
+			lastHour++
+		}
+	}
+	return 0.5*float64(lastHour) + 0.5*float64(lookback)/lookbackHours
+}
+
+// Periodically sends warm-ups to the edges a function is likely to be placed at
+type Warmer struct {
+	Invoker       Invoker
+	TopK          int
+	BudgetPerHour int
+	Interval      time.Duration
+}
+
+// Runs one round: forecasts traffic, picks the top-k likely locations per
+// function and warms those that would go cold before the next round
+func (w *Warmer) RunOnce(functions map[string]common.FunctionInfo) (WarmRound, error) {
+	var round WarmRound
+	var warmed []WarmupTarget
+	cfg := GetConfig()
+	now := time.Now().UTC()
+
+	state, err := LoadWarmerState()
+	if err != nil {
+		return round, err
+	}
+	profiles, err := LoadExecutionProfiles()
+	if err != nil {
+		return round, err
+	}
+	if now.Sub(state.BudgetWindow) >= time.Hour {
+		state.BudgetWindow = now
+		state.BudgetSpent = 0
+	}
+	budgetSpent, warmupsSent, warmupErrors := state.BudgetSpent, 0, 0
+
+	// busiest functions get the budget first
+	var targets []WarmupTarget
+	for name, function := range functions {
+		forecast := ForecastRate(state.Placements[name], now, cfg.Warmer.LookbackHours)
+		if forecast < cfg.Warmer.MinRatePerHour {
+			continue
+		}
+		locations, err := w.likelyLocations(function, state.Placements[name])
+		if err != nil {
+			return round, err
+		}
+		for _, location := range locations {
+			targets = append(targets, WarmupTarget{Function: name, Location: location, Forecast: forecast})
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].Forecast != targets[j].Forecast {
+			return targets[i].Forecast > targets[j].Forecast
+		}
+		return targets[i].Function < targets[j].Function
+	})
+
+	keepAlive := time.Duration(cfg.ColdStart.KeepAliveMinSeconds * float64(time.Second))
+	for _, target := range targets {
+		profile := profiles[target.Function][target.Location]
+		if profile != nil && profile.LastActive().Add(keepAlive).After(now.Add(w.Interval)) {
+			round.StillWarm++
+			continue
+		}
+		if w.BudgetPerHour > 0 && budgetSpent >= w.BudgetPerHour {
+			round.BudgetExhausted = true
+			break
+		}
+
+		budgetSpent++
+		if err := w.Invoker.Warm(functions[target.Function], target.Location); err != nil {
+			warmupErrors++
+			round.Errors = append(round.Errors, fmt.Errorf("%s at %s: %v", target.Function, target.Location, err))
+			continue
+		}
+		warmupsSent++
+		round.Sent = append(round.Sent, target)
+		warmed = append(warmed, target)
+	}
+
+	// warm-ups take a while, so placements and executions recorded meanwhile
+	// are re-read under the file locks and only the warmer's own fields are
+	// written back
+	err = updateDataFile(ExecutionProfileFile, func() error {
+		profiles, err := LoadExecutionProfiles()
+		if err != nil {
+			return err
+		}
+		for _, target := range warmed {
+			if profiles[target.Function] == nil {
+				profiles[target.Function] = make(FunctionProfiles)
+			}
+			if profiles[target.Function][target.Location] == nil {
+				profiles[target.Function][target.Location] = &ExecutionProfile{}
+			}
+			profiles[target.Function][target.Location].LastWarmed = now
+		}
+		return SaveExecutionProfiles(profiles)
+	})
+	if err != nil {
+		return round, err
+	}
+
+	budgetWindow := state.BudgetWindow
+	return round, updateDataFile(WarmerStateFile, func() error {
+		state, err := LoadWarmerState()
+		if err != nil {
+			return err
+		}
+		state.BudgetWindow = budgetWindow
+		state.BudgetSpent = budgetSpent
+		state.WarmupsSent += warmupsSent
+		state.WarmupErrors += warmupErrors
+		return SaveWarmerState(state)
+	})
+}
+
+// Locations the function was recently placed at, most frequent first, then
+// the edges with the best warm estimate, up to top-k in total
+func (w *Warmer) likelyLocations(function common.FunctionInfo, placements []PlacementRecord) ([]string, error) {
+	counts := make(map[string]int)
+	for _, placement := range placements {
+		counts[placement.Location]++
+	}
+	recent := make([]string, 0, len(counts))
+	for location := range counts {
+		recent = append(recent, location)
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		if counts[recent[i]] != counts[recent[j]] {
+			return counts[recent[i]] > counts[recent[j]]
+		}
+		return recent[i] < recent[j]
+	})
+
+	decision, err := RunOptLatency(function)
+	if err != nil {
+		return nil, err
+	}
+	candidates := decision.Candidates
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return warmEstimate(candidates[i]) < warmEstimate(candidates[j])
+	})
+
+	locations := make([]string, 0, w.TopK)
+	seen := make(map[string]bool)
+	add := func(location string) {
+		if len(locations) < w.TopK && !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+	for _, location := range recent {
+		add(location)
+	}
+	for _, candidate := range candidates {
+		if !candidate.Filtered {
+			add(candidate.Location)
+		}
+	}
+	return locations, nil
+}
+
+// Estimate the candidate would have with a warm instance
+func warmEstimate(candidate common.CandidateInfo) float64 {
+	return candidate.Estimate - candidate.ColdProbability*candidate.ColdPenalty
+}
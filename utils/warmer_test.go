@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"radsched/common"
+)
+
+// Records warm-ups instead of sending them
+type recordingInvoker struct {
+	warmed []string
+}
+
+func (invoker *recordingInvoker) Invoke(function common.FunctionInfo, location string) (InvocationResult, error) {
+	return InvocationResult{}, nil
+}
+
+func (invoker *recordingInvoker) Warm(function common.FunctionInfo, location string) error {
+	invoker.warmed = append(invoker.warmed, function.FunctionName+"@"+location)
+	return nil
+}
+
+func TestForecastRate(t *testing.T) {
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	var placements []PlacementRecord
+	for _, age := range []time.Duration{10 * time.Minute, 30 * time.Minute, 3 * time.Hour, 10 * time.Hour, 30 * time.Hour} {
+		placements = append(placements, PlacementRecord{Location: "eu-west-1", At: now.Add(-age)})
+	}
+	// 2 in the last hour, 4 over 24 hours
+	if got, want := ForecastRate(placements, now, 24), 0.5*2+0.5*4.0/24; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := ForecastRate(nil, now, 24); got != 0 {
+		t.Errorf("no placements: got %v, want 0", got)
+	}
+}
+
+// Warm-ups stop once the hourly budget is spent, instances warmed in an
+// earlier round are left alone, and a new window resets the budget
+func TestWarmerBudget(t *testing.T) {
+	withDataCopy(t)
+	withConfig(t, func(cfg *Config) {
+		cfg.Warmer.MinRatePerHour, cfg.Warmer.LookbackHours = 1, 24
+		cfg.ColdStart.KeepAliveMinSeconds = 300
+	})
+	all, err := GetFunctionsAsMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// three busy functions, each recently placed at its datacenter
+	now := time.Now().UTC()
+	state := WarmerState{Placements: make(map[string][]PlacementRecord)}
+	functions := make(map[string]common.FunctionInfo)
+	for _, name := range names[:3] {
+		functions[name] = all[name]
+		for i := 0; i < 5; i++ {
+			state.Placements[name] = append(state.Placements[name], PlacementRecord{Location: all[name].Datacenter, At: now.Add(-time.Duration(i) * time.Minute)})
+		}
+	}
+	if err := SaveWarmerState(state); err != nil {
+		t.Fatal(err)
+	}
+
+	invoker := &recordingInvoker{}
+	warmer := &Warmer{Invoker: invoker, TopK: 1, BudgetPerHour: 2, Interval: 4 * time.Minute}
+	rounds := []struct {
+		name          string
+		newWindow     bool
+		wantSent      int
+		wantStillWarm int
+		wantExhausted bool
+	}{
+		{"budget runs out", false, 2, 0, true},
+		{"same window", false, 0, 2, true},
+		{"next window", true, 1, 2, false},
+	}
+	for _, test := range rounds {
+		if test.newWindow {
+			state, err := LoadWarmerState()
+			if err != nil {
+				t.Fatal(err)
+			}
+			state.BudgetWindow = state.BudgetWindow.Add(-time.Hour)
+			if err := SaveWarmerState(state); err != nil {
+				t.Fatal(err)
+			}
+		}
+		round, err := warmer.RunOnce(functions)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(round.Sent) != test.wantSent || round.StillWarm != test.wantStillWarm || round.BudgetExhausted != test.wantExhausted {
+			t.Errorf("%s: sent %d, still warm %d, exhausted %t; want %d, %d, %t", test.name,
+				len(round.Sent), round.StillWarm, round.BudgetExhausted, test.wantSent, test.wantStillWarm, test.wantExhausted)
+		}
+	}
+
+	if len(invoker.warmed) != 3 {
+		t.Errorf("warmed %v, want each function once", invoker.warmed)
+	}
+	state, err = LoadWarmerState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.BudgetSpent != 1 || state.WarmupsSent != 3 {
+		t.Errorf("budget spent %d and %d warm-ups sent, want 1 and 3", state.BudgetSpent, state.WarmupsSent)
+	}
+}
+
+func TestRecordPlacementConcurrent(t *testing.T) {
+	withDataCopy(t)
+	const placements = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, placements)
+	for i := 0; i < placements; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- RecordPlacement("Concurrent", "eu-west-1")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	state, err := LoadWarmerState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(state.Placements["concurrent"]); got != placements {
+		t.Errorf("%d placements recorded, want %d", got, placements)
+	}
+}